@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/epfl-dcsl/schedsim/analytic"
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// analyticQuantiles returns the latency percentiles of the analytic models,
+// the ones selected by --percentiles or the default ones
+func (f *simFlags) analyticQuantiles() ([]float64, error) {
+	if f.percentiles == "" {
+		return blocks.DefaultPercentiles(), nil
+	}
+	return blocks.ParsePercentiles(strings.Split(f.percentiles, ","))
+}
+
+// analyticPoint returns the analytic model of the selected topology at the
+// given arrival rate
+func (f *simFlags) analyticPoint(lambda float64, quantiles []float64) (analytic.Result, error) {
+	if f.config != "" {
+		return analytic.Result{}, fmt.Errorf("topology files are not modelled")
+	}
+	p := f.params
+	var err error
+	if p.GenParams, err = blocks.ParseParams(f.genParams); err != nil {
+		return analytic.Result{}, err
+	}
+	if p.ProcParams, err = blocks.ParseParams(f.procParams); err != nil {
+		return analytic.Result{}, err
+	}
+	return analytic.Topology(p, lambda, quantiles)
+}
+
+// analyticSummary returns the analytic model as the summary of a collector
+// named analytic, to be compared with the simulated ones. The model should
+// report the selected percentiles.
+func analyticSummary(r analytic.Result) blocks.Summary {
+	return blocks.Summary{
+		Name:        "analytic",
+		Avg:         r.Avg,
+		StdDev:      math.NaN(),
+		Percentiles: r.Percentiles,
+		Throughput:  r.Lambda,
+		ServiceTime: r.ServiceTime,
+		QueueDelay:  r.QueueDelay,
+		Slowdown:    r.Slowdown,
+	}
+}
+
+func writeAnalyticCSV(w io.Writer, results []analytic.Result) error {
+	cw := csv.NewWriter(w)
+	for i, r := range results {
+		metrics := r.Metrics()
+		if i == 0 {
+			header := []string{"model", "exact"}
+			for _, m := range metrics {
+				header = append(header, m.Name)
+			}
+			cw.Write(header)
+		}
+		row := []string{r.Model, strconv.FormatBool(r.Exact)}
+		for _, m := range metrics {
+			row = append(row, ftoa(m.Value))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeAnalyticJSON(w io.Writer, results []analytic.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// analyticCmd prints the latency predicted by queueing theory for the
+// selected topology at several load levels
+func analyticCmd(args []string) {
+	fs := flag.NewFlagSet("analytic", flag.ExitOnError)
+	f := addSimFlags(fs)
+	var lambdas = fs.String("lambdas", "", "comma separated list of load levels")
+	var rng = fs.String("range", "", "load levels as start:stop:step, stop included")
+	var load = fs.Bool("load", false, "load levels are utilisation fractions of cores*mu instead of lambdas")
+	var format = fs.String("format", "csv", "output format: csv or json")
+	var out = fs.String("out", "", "output file, stdout if empty")
+	fs.Parse(args)
+
+	levels, err := parseLevels(*lambdas, *rng)
+	if err != nil {
+		fatal(err)
+	}
+	var write func(io.Writer, []analytic.Result) error
+	switch *format {
+	case "csv":
+		write = writeAnalyticCSV
+	case "json":
+		write = writeAnalyticJSON
+	default:
+		fatal(fmt.Errorf("unknown output format: %v", *format))
+	}
+	quantiles, err := f.analyticQuantiles()
+	if err != nil {
+		fatal(err)
+	}
+
+	points, err := f.sweepPoints(levels, *load)
+	if err != nil {
+		fatal(err)
+	}
+	var results []analytic.Result
+	for _, p := range points {
+		r, err := f.analyticPoint(p.Lambda, quantiles)
+		if err != nil {
+			fatal(err)
+		}
+		results = append(results, r)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := write(w, results); err != nil {
+		fatal(err)
+	}
+}
@@ -0,0 +1,233 @@
+// Package analytic computes the latency of the simple topologies from
+// queueing theory, to choose load levels and sanity check simulations.
+package analytic
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// Service describes a service time distribution by its moments
+type Service struct {
+	Kendall string  // M, D or G, as in the Kendall notation
+	Mean    float64 // mean service time
+	SCV     float64 // squared coefficient of variation
+	InvMean float64 // mean of the inverse of the service time, for slowdowns
+}
+
+// Exponential returns an exponential service with rate mu
+func Exponential(mu float64) Service {
+	return Service{"M", 1 / mu, 1, math.Inf(1)}
+}
+
+// Deterministic returns a fixed service time
+func Deterministic(d float64) Service {
+	return Service{"D", d, 0, 1 / d}
+}
+
+// Lognormal returns a lognormal service time whose logarithm has mean mu
+// and standard deviation sigma
+func Lognormal(mu, sigma float64) Service {
+	s2 := sigma * sigma
+	return Service{"G", math.Exp(mu + s2/2), math.Exp(s2) - 1, math.Exp(-mu + s2/2)}
+}
+
+// Bimodal returns a service time of v1 with probability ratio and v2
+// otherwise
+func Bimodal(v1, v2, ratio float64) Service {
+	mean := ratio*v1 + (1-ratio)*v2
+	second := ratio*v1*v1 + (1-ratio)*v2*v2
+	return Service{"G", mean, second/(mean*mean) - 1, ratio/v1 + (1-ratio)/v2}
+}
+
+// Result is the latency of a model at a given arrival rate. Values without a
+// known closed form are NaN, and the values of unstable systems infinite.
+type Result struct {
+	Model       string
+	Exact       bool // false for approximations
+	Lambda      float64
+	Load        float64 // utilisation of the servers
+	ServiceTime float64
+	WaitProb    float64 // probability of queueing
+	Avg         float64
+	QueueDelay  float64
+	Slowdown    float64
+	Percentiles blocks.Metrics // latency percentiles
+}
+
+func newResult(model string, lambda float64, s Service, c int, quantiles []float64) Result {
+	res := Result{
+		Model:       model,
+		Exact:       true,
+		Lambda:      lambda,
+		Load:        lambda * s.Mean / float64(c),
+		ServiceTime: s.Mean,
+		WaitProb:    math.NaN(),
+		Avg:         math.NaN(),
+		QueueDelay:  math.NaN(),
+		Slowdown:    math.NaN(),
+		Percentiles: make(blocks.Metrics, len(quantiles)),
+	}
+	for i, q := range quantiles {
+		res.Percentiles[i] = blocks.Metric{Name: blocks.PercentileName(q), Value: math.NaN()}
+	}
+	return res
+}
+
+// Metrics returns the values of the result, named like the metrics of the
+// collectors
+func (r Result) Metrics() blocks.Metrics {
+	res := blocks.Metrics{
+		{Name: "lambda", Value: r.Lambda},
+		{Name: "load", Value: r.Load},
+		{Name: "service_time", Value: r.ServiceTime},
+		{Name: "wait_prob", Value: r.WaitProb},
+		{Name: "avg", Value: r.Avg},
+		{Name: "queue_delay", Value: r.QueueDelay},
+		{Name: "slowdown", Value: r.Slowdown},
+	}
+	return append(res, r.Percentiles...)
+}
+
+// MarshalJSON implements json.Marshaler, with null for the values that are
+// unknown or infinite
+func (r Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Model   string         `json:"model"`
+		Exact   bool           `json:"exact"`
+		Metrics blocks.Metrics `json:"metrics"`
+	}{r.Model, r.Exact, r.Metrics()})
+}
+
+// unstable marks every value of r infinite
+func (r *Result) unstable() {
+	r.WaitProb = 1
+	r.Avg = math.Inf(1)
+	r.QueueDelay = math.Inf(1)
+	r.Slowdown = math.Inf(1)
+	for i := range r.Percentiles {
+		r.Percentiles[i].Value = math.Inf(1)
+	}
+}
+
+// ErlangC returns the probability that a request waits in an M/M/c queue
+// with offered load a = lambda/mu
+func ErlangC(c int, a float64) float64 {
+	if a >= float64(c) {
+		return 1
+	}
+	// Erlang B by recursion, then C from B
+	b := 1.0
+	for k := 1; k <= c; k++ {
+		b = a * b / (float64(k) + a*b)
+	}
+	rho := a / float64(c)
+	return b / (1 - rho + rho*b)
+}
+
+// FCFS returns the latency of c servers taking requests from a single first
+// come first served queue fed by poisson arrivals. It is exact for
+// exponential service times (M/M/c) and a single server (M/G/1, with the
+// Pollaczek-Khinchine formula), and uses the Allen-Cunneen approximation of
+// the queueing delay otherwise. Latency percentiles are only known for
+// exponential service times.
+func FCFS(lambda float64, s Service, c int, quantiles []float64) Result {
+	a := lambda * s.Mean
+	res := newResult(fmt.Sprintf("M/%v/%v", s.Kendall, c), lambda, s, c, quantiles)
+	if res.Load >= 1 {
+		res.unstable()
+		return res
+	}
+	res.WaitProb = ErlangC(c, a)
+	// The M/M/c queueing delay, scaled by the variability of the service
+	res.QueueDelay = res.WaitProb * s.Mean / (float64(c) - a) * (1 + s.SCV) / 2
+	res.Exact = s.Kendall == "M" || c == 1
+	res.Avg = res.QueueDelay + s.Mean
+	// The queueing delay is independent of the service time
+	res.Slowdown = 1 + res.QueueDelay*s.InvMean
+	if s.Kendall == "M" {
+		for i, q := range quantiles {
+			res.Percentiles[i].Value = mmcQuantile(1/s.Mean, float64(c)/s.Mean-lambda, res.WaitProb, q)
+		}
+	}
+	return res
+}
+
+// mmcQuantile returns the q quantile of the latency of an M/M/c queue with
+// service rate mu, where requests wait with probability pWait for an
+// exponential time of rate rate = c*mu - lambda
+func mmcQuantile(mu, rate, pWait, q float64) float64 {
+	if q <= 0 {
+		return 0
+	}
+	if q >= 1 {
+		return math.Inf(1)
+	}
+	// The latency is the service time, plus the queueing delay if waiting
+	tail := func(t float64) float64 {
+		var waited float64
+		if math.Abs(rate-mu) < 1e-12*mu {
+			waited = math.Exp(-mu*t) * (1 + mu*t)
+		} else {
+			waited = (rate*math.Exp(-mu*t) - mu*math.Exp(-rate*t)) / (rate - mu)
+		}
+		return (1-pWait)*math.Exp(-mu*t) + pWait*waited
+	}
+	// The tail is decreasing: bracket the quantile, then bisect
+	lo, hi := 0.0, 1/mu
+	for tail(hi) > 1-q {
+		lo, hi = hi, 2*hi
+	}
+	for i := 0; i < 100 && hi-lo > 1e-12*hi; i++ {
+		mid := (lo + hi) / 2
+		if tail(mid) > 1-q {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// PS returns the latency of poisson arrivals served by c servers sharing
+// their capacity among the requests, each request getting at most a server.
+// This queue is insensitive to the service time distribution: its mean
+// latency is the one of the M/M/c queue, and every request is slowed down by
+// 1/(1-load) with a single server. Requests never wait.
+func PS(lambda float64, s Service, c int, quantiles []float64) Result {
+	a := lambda * s.Mean
+	res := newResult(fmt.Sprintf("M/%v/%v-PS", s.Kendall, c), lambda, s, c, quantiles)
+	if res.Load >= 1 {
+		res.unstable()
+		return res
+	}
+	res.WaitProb = 0
+	res.QueueDelay = 0
+	res.Avg = s.Mean + ErlangC(c, a)*s.Mean/(float64(c)-a)
+	if c == 1 {
+		res.Slowdown = 1 / (1 - res.Load)
+	}
+	return res
+}
+
+// TimeSharing returns the mean latency of c servers serving requests in
+// round robin from a single queue. With exponential service times the
+// number of requests in the system, and so the mean latency, is the one of
+// the M/M/c queue whatever the quantum. Other service times are not
+// supported.
+func TimeSharing(lambda float64, s Service, c int, quantiles []float64) (Result, error) {
+	if s.Kendall != "M" {
+		return Result{}, fmt.Errorf("time sharing is only modelled with exponential service times")
+	}
+	a := lambda * s.Mean
+	res := newResult(fmt.Sprintf("M/M/%v-RR", c), lambda, s, c, quantiles)
+	if res.Load >= 1 {
+		res.unstable()
+		return res, nil
+	}
+	res.Avg = s.Mean + ErlangC(c, a)*s.Mean/(float64(c)-a)
+	return res, nil
+}
@@ -0,0 +1,133 @@
+package analytic_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/analytic"
+)
+
+func checkClose(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if math.IsInf(want, 1) && math.IsInf(got, 1) {
+		return
+	}
+	if math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+		t.Errorf("%v: got %v, want %v", what, got, want)
+	}
+}
+
+func TestErlangC(t *testing.T) {
+	tests := []struct {
+		c    int
+		a, p float64
+	}{
+		{1, 0.5, 0.5},
+		{1, 0.9, 0.9},
+		{2, 1, 1.0 / 3},
+		{3, 2, 4.0 / 9},
+		// Erlang B is 2/21
+		{4, 2, 4.0 / 23},
+		{2, 2, 1},
+		{2, 3, 1},
+	}
+	for _, tt := range tests {
+		checkClose(t, "ErlangC", analytic.ErlangC(tt.c, tt.a), tt.p)
+	}
+}
+
+// Known latencies of M/M/c queues with a service rate of 1: requests wait
+// with probability C for an exponential time of rate c-lambda
+func TestFCFSMMc(t *testing.T) {
+	qs := []float64{0.5, 0.99}
+	tests := []struct {
+		lambda   float64
+		c        int
+		waitProb float64
+		avg      float64
+		p50, p99 float64
+	}{
+		// M/M/1: the latency is exponential with rate 1-lambda
+		{0.5, 1, 0.5, 2, 2 * math.Ln2, 2 * math.Log(100)},
+		{0.9, 1, 0.9, 10, 10 * math.Ln2, 10 * math.Log(100)},
+		// M/M/2 at lambda 1: the latency tail is exp(-t)(1+t/3)
+		{1, 2, 1.0 / 3, 4.0 / 3, 0.9744116051899289, 5.6659606837929966},
+		{2, 3, 4.0 / 9, 13.0 / 9, math.NaN(), math.NaN()},
+	}
+	for _, tt := range tests {
+		r := analytic.FCFS(tt.lambda, analytic.Exponential(1), tt.c, qs)
+		if !r.Exact {
+			t.Errorf("%v: not exact", r.Model)
+		}
+		checkClose(t, r.Model+" load", r.Load, tt.lambda/float64(tt.c))
+		checkClose(t, r.Model+" wait probability", r.WaitProb, tt.waitProb)
+		checkClose(t, r.Model+" latency", r.Avg, tt.avg)
+		checkClose(t, r.Model+" queueing delay", r.QueueDelay, tt.avg-1)
+		if !math.IsNaN(tt.p50) {
+			checkClose(t, r.Model+" p50", r.Percentiles[0].Value, tt.p50)
+			checkClose(t, r.Model+" p99", r.Percentiles[1].Value, tt.p99)
+		}
+	}
+}
+
+func TestFCFSMG1(t *testing.T) {
+	// Pollaczek-Khinchine: the queueing delay is load*mean*(1+scv)/(2(1-load))
+	tests := []struct {
+		name  string
+		s     analytic.Service
+		delay float64
+	}{
+		{"M/D/1", analytic.Deterministic(1), 0.5},
+		{"M/M/1", analytic.Exponential(1), 1},
+		// Mean 1, second moment 0.5*0.25+0.5*2.25 = 1.25, scv 0.25
+		{"M/G/1", analytic.Bimodal(0.5, 1.5, 0.5), 0.625},
+	}
+	for _, tt := range tests {
+		r := analytic.FCFS(0.5, tt.s, 1, nil)
+		if r.Model != tt.name || !r.Exact {
+			t.Errorf("%v: model %v, exact %v", tt.name, r.Model, r.Exact)
+		}
+		checkClose(t, tt.name+" queueing delay", r.QueueDelay, tt.delay)
+		checkClose(t, tt.name+" latency", r.Avg, 1+tt.delay)
+	}
+	if r := analytic.FCFS(1, analytic.Deterministic(1), 2, nil); r.Exact {
+		t.Errorf("%v: exact", r.Model)
+	}
+}
+
+func TestPSAndTimeSharing(t *testing.T) {
+	// Insensitive to the service time distribution
+	for _, s := range []analytic.Service{analytic.Exponential(1), analytic.Deterministic(1)} {
+		r := analytic.PS(0.5, s, 1, nil)
+		checkClose(t, r.Model+" latency", r.Avg, 2)
+		checkClose(t, r.Model+" slowdown", r.Slowdown, 2)
+		checkClose(t, r.Model+" queueing delay", r.QueueDelay, 0)
+	}
+	r := analytic.PS(1, analytic.Exponential(1), 2, nil)
+	checkClose(t, r.Model+" latency", r.Avg, 4.0/3)
+
+	r, err := analytic.TimeSharing(1, analytic.Exponential(1), 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClose(t, r.Model+" latency", r.Avg, 4.0/3)
+	if _, err := analytic.TimeSharing(0.5, analytic.Deterministic(1), 1, nil); err == nil {
+		t.Error("time sharing with deterministic service times: no error")
+	}
+}
+
+func TestUnstable(t *testing.T) {
+	for _, r := range []analytic.Result{
+		analytic.FCFS(2, analytic.Exponential(1), 2, []float64{0.99}),
+		analytic.PS(3, analytic.Exponential(1), 2, nil),
+	} {
+		if r.WaitProb != 1 || !math.IsInf(r.Avg, 1) || !math.IsInf(r.QueueDelay, 1) {
+			t.Errorf("%v at load %v: %+v", r.Model, r.Load, r)
+		}
+		for _, p := range r.Percentiles {
+			if !math.IsInf(p.Value, 1) {
+				t.Errorf("%v at load %v: %v %v", r.Model, r.Load, p.Name, p.Value)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package analytic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/topologies"
+)
+
+// GeneratorService returns the service time distribution of a registered
+// generator with poisson arrivals, and whether it sends requests to a random
+// queue rather than in round robin
+func GeneratorService(name string, params blocks.Params) (Service, bool, error) {
+	info, ok := blocks.LookupGenerator(name)
+	if !ok {
+		return Service{}, false, fmt.Errorf("unknown generator: %v", name)
+	}
+	p, err := info.Validate(params)
+	if err != nil {
+		return Service{}, false, err
+	}
+	random := strings.HasSuffix(name, "Rand")
+	switch name {
+	case "MM", "MMRand":
+		return Exponential(p.Float("mu")), random, nil
+	case "MD", "MDRand":
+		return Deterministic(p.Float("serviceTime")), random, nil
+	case "MB", "MBRand":
+		return Bimodal(p.Float("peak1"), p.Float("peak2"), p.Float("ratio")), random, nil
+	case "MLN":
+		return Lognormal(p.Float("mu"), p.Float("sigma")), random, nil
+	}
+	return Service{}, false, fmt.Errorf("no model of generator %v", name)
+}
+
+// Topology returns the latency of the predefined topology selected by p at
+// arrival rate lambda, with the latency percentiles at quantiles. The
+// single queue is modelled with the queue of its processor, and the multi
+// queue, fed at random, as independent single server queues.
+func Topology(p topologies.Params, lambda float64, quantiles []float64) (Result, error) {
+	p.Lambda = lambda
+	genName, genParams, err := p.GeneratorParams()
+	if err != nil {
+		return Result{}, err
+	}
+	s, random, err := GeneratorService(genName, genParams)
+	if err != nil {
+		return Result{}, err
+	}
+	random = random || p.Dispatch == "random"
+	if p.Dispatch != "" && p.Dispatch != "random" {
+		return Result{}, fmt.Errorf("dispatch policies other than random are not modelled")
+	}
+	procName, err := p.ProcessorName()
+	if err != nil {
+		return Result{}, err
+	}
+	info, ok := blocks.LookupProcessor(procName)
+	if !ok {
+		return Result{}, fmt.Errorf("unknown processor: %v", procName)
+	}
+	procParams, err := info.Validate(p.ProcParams)
+	if err != nil {
+		return Result{}, err
+	}
+	if info.HasParam("ctxCost") && procParams.Float("ctxCost") != 0 {
+		return Result{}, fmt.Errorf("scheduling overheads are not modelled")
+	}
+
+	cores := p.Cores
+	if cores < 1 {
+		return Result{}, fmt.Errorf("bad core count: %v", cores)
+	}
+	switch p.Topo {
+	case 0:
+	case 1:
+		if !random && cores > 1 {
+			return Result{}, fmt.Errorf("round robin arrivals to several queues are not modelled, use a random generator")
+		}
+		// Every queue gets a poisson share of the arrivals
+		res, err := processorModel(procName, lambda/float64(cores), s, 1, quantiles)
+		if err != nil {
+			return Result{}, err
+		}
+		res.Lambda = lambda
+		res.Model = fmt.Sprintf("%v x %v", cores, res.Model)
+		return res, nil
+	default:
+		return Result{}, fmt.Errorf("no model of topology %v", p.Topo)
+	}
+	return processorModel(procName, lambda, s, cores, quantiles)
+}
+
+// processorModel returns the model of c processors of the given type
+// sharing a queue
+func processorModel(name string, lambda float64, s Service, c int, quantiles []float64) (Result, error) {
+	switch name {
+	case "rtc":
+		return FCFS(lambda, s, c, quantiles), nil
+	case "ps":
+		return PS(lambda, s, c, quantiles), nil
+	case "ts":
+		return TimeSharing(lambda, s, c, quantiles)
+	}
+	return Result{}, fmt.Errorf("no model of processor %v", name)
+}
+
+// ConfigDemand returns the work brought by the generators of a topology file
+// per unit of arrival rate, the sum of their mean service times. Every
+// generator should take lambda, which Config.SetLambda sets.
+func ConfigDemand(c *topologies.Config) (float64, error) {
+	var res float64
+	for _, gc := range c.Generators {
+		if info, ok := blocks.LookupGenerator(gc.Type); !ok || !info.HasParam("lambda") {
+			return 0, fmt.Errorf("generator %v has no arrival rate", gc.Name)
+		}
+		s, _, err := GeneratorService(gc.Type, gc.Params)
+		if err != nil {
+			return 0, fmt.Errorf("generator %v: %v", gc.Name, err)
+		}
+		res += s.Mean
+	}
+	if res == 0 {
+		return 0, fmt.Errorf("no generators")
+	}
+	return res, nil
+}
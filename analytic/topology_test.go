@@ -0,0 +1,73 @@
+package analytic_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/analytic"
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/topologies"
+)
+
+func TestTopology(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     topologies.Params
+		model string
+		avg   float64
+		err   string // expected error substring, empty if valid
+	}{
+		{"single queue", topologies.Params{Topo: 0, Cores: 2, Mu: 1}, "M/M/2", 4.0 / 3, ""},
+		{"multi queue", topologies.Params{Topo: 1, Cores: 4, Mu: 1}, "4 x M/M/1", 2, ""},
+		{"deterministic", topologies.Params{Topo: 0, Cores: 1, Mu: 1, GenType: 1}, "M/D/1", 1.5, ""},
+		{"processor sharing", topologies.Params{Topo: 0, Cores: 1, Mu: 1, ProcType: 1}, "M/M/1-PS", 2, ""},
+		{"random dispatch", topologies.Params{Topo: 1, Cores: 2, Mu: 1, Generator: "MM",
+			Dispatch: "random"}, "2 x M/M/1", 2, ""},
+		{"round robin", topologies.Params{Topo: 1, Cores: 2, Mu: 1, Generator: "MM"}, "", 0,
+			"round robin"},
+		{"jsq", topologies.Params{Topo: 1, Cores: 2, Mu: 1, Dispatch: "jsq"}, "", 0, "not modelled"},
+		{"ctxCost", topologies.Params{Topo: 0, Cores: 1, Mu: 1,
+			ProcParams: blocks.Params{"ctxCost": 1.0}}, "", 0, "overheads"},
+		{"dispatcher", topologies.Params{Topo: 3, Cores: 1, Mu: 1}, "", 0, "no model of topology"},
+		{"no cores", topologies.Params{Topo: 0, Mu: 1}, "", 0, "bad core count"},
+	}
+	for _, tt := range tests {
+		// Load 0.5 on every core
+		lambda := 0.5 * float64(tt.p.Cores)
+		if tt.p.Cores == 0 {
+			lambda = 0.5
+		}
+		r, err := analytic.Topology(tt.p, lambda, nil)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: got error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.name, err)
+			continue
+		}
+		if r.Model != tt.model || r.Lambda != lambda {
+			t.Errorf("%v: model %v at %v, want %v at %v", tt.name, r.Model, r.Lambda, tt.model, lambda)
+		}
+		checkClose(t, tt.name+" latency", r.Avg, tt.avg)
+	}
+}
+
+func TestConfigDemand(t *testing.T) {
+	c := &topologies.Config{Generators: []topologies.GeneratorConfig{
+		{Name: "a", Type: "MM", Params: blocks.Params{"mu": 0.5}},
+		{Name: "b", Type: "MD", Params: blocks.Params{"serviceTime": 3.0}},
+	}}
+	demand, err := analytic.ConfigDemand(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClose(t, "demand", demand, 5)
+
+	c.Generators = append(c.Generators, topologies.GeneratorConfig{Name: "dd", Type: "DD"})
+	if _, err := analytic.ConfigDemand(c); err == nil || !strings.Contains(err.Error(), "no arrival rate") {
+		t.Errorf("generator without arrival rate: got error %v", err)
+	}
+}
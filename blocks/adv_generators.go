@@ -2,53 +2,195 @@ package blocks
 
 import (
 	"bufio"
-	"math/rand"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
+func init() {
+	RegisterGenerator("PB", "poisson arrivals, service times played back from files",
+		[]Param{lambdaParam, {"paths", StringListParam, nil, "files with one service time per line, one per cpu"}},
+		func(p Params) (Generator, error) {
+			g, err := NewPBGenerator(p.Float("lambda"), p.Strings("paths"))
+			if err != nil {
+				return nil, err
+			}
+			return g, nil
+		})
+	RegisterGenerator("mix", "poisson arrivals, requests of several classes, random queue",
+		[]Param{lambdaParam, {"workloads", WorkloadsParam, nil,
+			"classes as name=share@distribution(param=value;...), like short=0.9@deterministic(value=1)"}},
+		func(p Params) (Generator, error) {
+			return NewMixGenerator(p.Float("lambda"), p.Workloads("workloads")), nil
+		})
+}
+
 // PBGenerator implements a playback generator for given service times.
 // The interarrival distribution is exponential
 type PBGenerator struct {
 	genericGenerator
 	sTimes   [][]int
 	cpuCount int
-	WaitTime randDist
+	WaitTime RandDist
 }
 
 // NewPBGenerator returns a PBGenerator
 // Parameters: lambda for the exponential interarrival and the filenames
-// with the service times
-func NewPBGenerator(lambda float64, paths []string) *PBGenerator {
+// with the service times. Every file should hold at least one service time.
+func NewPBGenerator(lambda float64, paths []string) (*PBGenerator, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("PB: no service time files")
+	}
 	g := PBGenerator{}
-
 	for _, p := range paths {
-		/* Read service times */
-		inFile, _ := os.Open(p)
-		defer inFile.Close()
-		scanner := bufio.NewScanner(inFile)
-		scanner.Split(bufio.ScanLines)
-
-		newTimes := make([]int, 0)
-		for scanner.Scan() {
-			n, _ := strconv.Atoi(scanner.Text())
-			newTimes = append(newTimes, n)
+		newTimes, err := readServiceTimes(p)
+		if err != nil {
+			return nil, fmt.Errorf("PB: %v", err)
 		}
 		g.sTimes = append(g.sTimes, newTimes)
 	}
 	g.cpuCount = len(paths)
 	g.WaitTime = newExponDistr(lambda)
-	return &g
+	return &g, nil
+}
+
+// readServiceTimes reads a file with one integer service time per line
+func readServiceTimes(path string) ([]int, error) {
+	inFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer inFile.Close()
+	scanner := bufio.NewScanner(inFile)
+	scanner.Split(bufio.ScanLines)
+
+	res := make([]int, 0)
+	for line := 1; scanner.Scan(); line++ {
+		n, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: bad service time: %v", path, line, err)
+		}
+		res = append(res, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("%v: no service times", path)
+	}
+	return res, nil
 }
 
 // Run is the main loop of the generator
 func (g *PBGenerator) Run() {
 	for {
-		i := rand.Intn(g.cpuCount)
-		j := rand.Intn(len(g.sTimes[i]))
+		i := g.Rand().Intn(g.cpuCount)
+		j := g.Rand().Intn(len(g.sTimes[i]))
 		serviceTime := g.sTimes[i][j]
 		req := g.Creator.NewRequest(float64(serviceTime))
 		g.WriteOutQueueI(req, i)
-		g.Wait(g.WaitTime.getRand())
+		g.Wait(g.WaitTime.getRand(g.Rand()))
+	}
+}
+
+// Workload is a class of requests of a MixGenerator, with its own service
+// time distribution and share of the traffic
+type Workload struct {
+	Name    string
+	Share   float64
+	Service RandDist
+}
+
+// ParseWorkload parses a workload given as name=share@distribution or
+// name=share@distribution(param=value;...), with a registered distribution
+func ParseWorkload(s string) (Workload, error) {
+	bad := func(err interface{}) (Workload, error) {
+		return Workload{}, fmt.Errorf("bad workload %q: %v", s, err)
+	}
+	nameShare, dist, ok := strings.Cut(s, "@")
+	if !ok {
+		return bad("expected name=share@distribution")
+	}
+	name, shareStr, ok := strings.Cut(nameShare, "=")
+	if !ok || name == "" {
+		return bad("expected name=share@distribution")
+	}
+	share, err := strconv.ParseFloat(shareStr, 64)
+	if err != nil || share <= 0 {
+		return bad("share should be a positive number")
+	}
+	service, err := ParseDistribution(dist)
+	if err != nil {
+		return bad(err)
+	}
+	return Workload{name, share, service}, nil
+}
+
+// parseComponent parses a component given as name or
+// name(param=value;...). Values are kept as strings.
+func parseComponent(s string) (string, Params, error) {
+	params := Params{}
+	name, args, ok := strings.Cut(s, "(")
+	if !ok {
+		return s, params, nil
+	}
+	if !strings.HasSuffix(args, ")") {
+		return "", nil, fmt.Errorf("missing )")
+	}
+	if args = strings.TrimSuffix(args, ")"); args != "" {
+		for _, kv := range strings.Split(args, ";") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return "", nil, fmt.Errorf("bad parameter %q: expected name=value", kv)
+			}
+			params[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return name, params, nil
+}
+
+// MixGenerator generates requests of several workloads with poisson
+// arrivals and feeds the output queues randomly, unless another dispatch
+// policy is set. Every request belongs to the class of its workload.
+type MixGenerator struct {
+	dispatchingGenerator
+	workloads []Workload
+	total     float64
+}
+
+// NewMixGenerator returns a MixGenerator with the given total arrival rate.
+// Workloads get a share of the arrivals proportional to their Share.
+func NewMixGenerator(lambda float64, workloads []Workload) *MixGenerator {
+	g := &MixGenerator{workloads: workloads}
+	g.dispatcher = RandomDispatcher{}
+	for _, w := range workloads {
+		g.total += w.Share
+	}
+	g.WaitTime = newExponDistr(lambda)
+	return g
+}
+
+func (g *MixGenerator) pickWorkload() Workload {
+	x := g.Rand().Float64() * g.total
+	for _, w := range g.workloads {
+		if x < w.Share {
+			return w
+		}
+		x -= w.Share
+	}
+	return g.workloads[len(g.workloads)-1]
+}
+
+// Run is the main loop of the generator
+func (g *MixGenerator) Run() {
+	for {
+		w := g.pickWorkload()
+		req := g.Creator.NewRequest(w.Service.getRand(g.Rand()))
+		if r, ok := req.(classedReq); ok {
+			r.setClass(w.Name)
+		}
+		g.dispatch(req)
+		g.Wait(g.WaitTime.getRand(g.Rand()))
 	}
 }
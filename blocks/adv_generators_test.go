@@ -0,0 +1,109 @@
+package blocks_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+func TestPBGeneratorPaths(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good", "10\n20\n")
+	tests := []struct {
+		paths []string
+		err   string // expected error substring, empty if valid
+	}{
+		{[]string{good}, ""},
+		{[]string{good, good}, ""},
+		{[]string{}, "no service time files"},
+		{[]string{filepath.Join(dir, "missing")}, "no such file"},
+		{[]string{good, write("empty", "")}, "no service times"},
+		{[]string{write("bad", "10\nx\n")}, "bad:2: bad service time"},
+	}
+	for _, tt := range tests {
+		_, err := blocks.NewGenerator("PB", blocks.Params{"paths": tt.paths})
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.paths, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: got error %v, want %q", tt.paths, err, tt.err)
+		}
+	}
+}
+
+func TestParseWorkload(t *testing.T) {
+	tests := []struct {
+		s     string
+		name  string
+		share float64
+		err   string // expected error substring, empty if valid
+	}{
+		{"short=0.9@deterministic(value=10)", "short", 0.9, ""},
+		{"long=1@exponential(lambda=0.01)", "long", 1, ""},
+		{"x=2@bimodal(v1=1;v2=10;ratio=0.5)", "x", 2, ""},
+		{"short@deterministic(value=10)", "", 0, "expected name=share@distribution"},
+		{"short=0.9", "", 0, "expected name=share@distribution"},
+		{"=0.9@deterministic(value=10)", "", 0, "expected name=share@distribution"},
+		{"short=0@deterministic(value=10)", "", 0, "share should be a positive number"},
+		{"short=a@deterministic(value=10)", "", 0, "share should be a positive number"},
+		{"short=0.9@unknown", "", 0, "unknown"},
+		{"short=0.9@deterministic(value=10", "", 0, "missing )"},
+	}
+	for _, tt := range tests {
+		w, err := blocks.ParseWorkload(tt.s)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got error %v, want %q", tt.s, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || w.Name != tt.name || w.Share != tt.share || w.Service == nil {
+			t.Errorf("%q: got %+v, %v, want %v with share %v", tt.s, w, err, tt.name, tt.share)
+		}
+	}
+}
+
+// Every class gets its share of the arrivals
+func TestMixGeneratorShares(t *testing.T) {
+	t.Parallel()
+	var workloads []blocks.Workload
+	for _, s := range []string{"a=1@deterministic(value=1)", "b=3@deterministic(value=2)"} {
+		w, err := blocks.ParseWorkload(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		workloads = append(workloads, w)
+	}
+	sim, stats := newTestSim(engine.Coroutines)
+	stats.SetName("stats")
+	connect(sim, stats, blocks.NewMixGenerator(0.01, workloads), []blocks.Processor{rtc()})
+	sim.Run(1000000)
+
+	sum := stats.GetSummary()
+	if len(sum.PerClass) != 2 {
+		t.Fatalf("%v request classes, want 2", len(sum.PerClass))
+	}
+	for i, want := range []struct {
+		name        string
+		share, serv float64
+	}{{"stats/a", 0.25, 1}, {"stats/b", 0.75, 2}} {
+		c := sum.PerClass[i]
+		share := float64(c.Count) / float64(sum.Count)
+		if c.Name != want.name || math.Abs(share-want.share) > 0.02 || c.ServiceTime != want.serv {
+			t.Errorf("class %v: %v of the requests with service time %v, want %v: %v and %v",
+				c.Name, share, c.ServiceTime, want.name, want.share, want.serv)
+		}
+	}
+}
@@ -1,8 +1,7 @@
 package blocks
 
 import (
-	"math/rand"
-	"time"
+	"fmt"
 
 	"github.com/epfl-dcsl/schedsim/engine"
 )
@@ -14,175 +13,162 @@ type Generator interface {
 	SetCreator(ReqCreator)
 }
 
+var lambdaParam = Param{"lambda", FloatParam, 0.005, "arrival rate"}
+
+func init() {
+	RegisterGenerator("dist", "interarrival and service times from any distributions, any dispatch policy",
+		[]Param{lambdaParam,
+			{"interarrival", DistributionParam, "", "interarrival time distribution, scaled to a mean of 1/lambda, poisson arrivals if empty"},
+			{"service", DistributionParam, nil, "service time distribution, like exponential(lambda=0.02)"},
+			{"dispatch", DispatcherParam, "random", "dispatch policy"}},
+		func(p Params) (Generator, error) {
+			interarrival := p.Distribution("interarrival")
+			if interarrival == nil {
+				interarrival = newExponDistr(p.Float("lambda"))
+			} else {
+				// The shape comes from the distribution, the rate from lambda
+				var err error
+				interarrival, err = withMean(interarrival, 1/p.Float("lambda"))
+				if err != nil {
+					return nil, fmt.Errorf("dist: interarrival: %v", err)
+				}
+			}
+			return NewDistGenerator(interarrival, p.Distribution("service"), p.Dispatcher("dispatch")), nil
+		})
+	RegisterGenerator("DD", "fixed interarrival and service times, round robin",
+		[]Param{{"waitTime", FloatParam, 200.0, "interarrival time"},
+			{"serviceTime", FloatParam, 50.0, "service time"}},
+		func(p Params) (Generator, error) {
+			return NewDDGenerator(p.Float("waitTime"), p.Float("serviceTime")), nil
+		})
+	RegisterGenerator("MD", "poisson arrivals, fixed service time, round robin",
+		[]Param{lambdaParam, {"serviceTime", FloatParam, 50.0, "service time"}},
+		func(p Params) (Generator, error) {
+			return NewMDGenerator(p.Float("lambda"), p.Float("serviceTime")), nil
+		})
+	RegisterGenerator("MDRand", "poisson arrivals, fixed service time, random queue",
+		[]Param{lambdaParam, {"serviceTime", FloatParam, 50.0, "service time"}},
+		func(p Params) (Generator, error) {
+			return NewMDRandGenerator(p.Float("lambda"), p.Float("serviceTime")), nil
+		})
+	RegisterGenerator("MM", "poisson arrivals, exponential service time, round robin",
+		[]Param{lambdaParam, {"mu", FloatParam, 0.02, "service rate"}},
+		func(p Params) (Generator, error) {
+			return NewMMGenerator(p.Float("lambda"), p.Float("mu")), nil
+		})
+	RegisterGenerator("MMRand", "poisson arrivals, exponential service time, random queue",
+		[]Param{lambdaParam, {"mu", FloatParam, 0.02, "service rate"}},
+		func(p Params) (Generator, error) {
+			return NewMMRandGenerator(p.Float("lambda"), p.Float("mu")), nil
+		})
+	RegisterGenerator("MLN", "poisson arrivals, lognormal service time, round robin",
+		[]Param{lambdaParam, {"mu", FloatParam, 0.0, "mean of the underlying normal"},
+			{"sigma", FloatParam, 1.0, "stddev of the underlying normal"}},
+		func(p Params) (Generator, error) {
+			return NewMLNGenerator(p.Float("lambda"), p.Float("mu"), p.Float("sigma")), nil
+		})
+	biParams := []Param{lambdaParam,
+		{"peak1", FloatParam, 1.0, "first service time"},
+		{"peak2", FloatParam, 100.0, "second service time"},
+		{"ratio", FloatParam, 0.9, "fraction of requests with the first service time"}}
+	RegisterGenerator("MB", "poisson arrivals, bimodal service time, round robin", biParams,
+		func(p Params) (Generator, error) {
+			return NewMBGenerator(p.Float("lambda"), p.Float("peak1"), p.Float("peak2"), p.Float("ratio")), nil
+		})
+	RegisterGenerator("MBRand", "poisson arrivals, bimodal service time, random queue", biParams,
+		func(p Params) (Generator, error) {
+			return NewMBRandGenerator(p.Float("lambda"), p.Float("peak1"), p.Float("peak2"), p.Float("ratio")), nil
+		})
+}
+
 type genericGenerator struct {
 	engine.Actor
 	Creator     ReqCreator
-	ServiceTime randDist
-	WaitTime    randDist
+	ServiceTime RandDist
+	WaitTime    RandDist
 }
 
 func (g *genericGenerator) SetCreator(rc ReqCreator) {
 	g.Creator = rc
 }
 
-type randGenerator struct {
-	genericGenerator
-}
-
-func (g *randGenerator) Run() {
-	for {
-		req := g.Creator.NewRequest(g.ServiceTime.getRand())
-		qIdx := rand.Intn(g.GetOutQueueCount())
-		if monitorReq, ok := req.(*MonitorReq); ok {
-			monitorReq.initLength = g.GetAllOutQueueLens()[qIdx]
-		}
-		g.WriteOutQueueI(req, qIdx)
-		g.Wait(g.WaitTime.getRand())
-	}
-}
-
-type rRGenerator struct {
-	genericGenerator
-}
-
-func (g *rRGenerator) Run() {
-	for count := 0; ; count++ {
-		req := g.Creator.NewRequest(g.ServiceTime.getRand())
-		g.WriteOutQueueI(req, count%g.GetOutQueueCount())
-		g.Wait(g.WaitTime.getRand())
-	}
-}
-
-// DDGenerator is a fixed waiting time generator that produces fixed service time requests
-type DDGenerator struct {
-	rRGenerator
-}
-
-// NewDDGenerator returns a DDGenerator
-func NewDDGenerator(waitTime, serviceTime float64) *DDGenerator {
-	g := &DDGenerator{}
-	g.ServiceTime = newDeterministicDistr(serviceTime)
-	g.WaitTime = newDeterministicDistr(waitTime)
-	return g
-}
-
-// MDGenerator is a exponential waiting time generator that produces fixed service time requests
-// If multiple queues they are fed round robin
-type MDGenerator struct {
-	rRGenerator
+// DistGenerator generates requests with interarrival and service times
+// drawn from two distributions and sends them to the output queues chosen
+// by a dispatcher. The named generators are DistGenerators.
+type DistGenerator struct {
+	dispatchingGenerator
 }
 
-// NewMDGenerator returns a MDGenerator
-func NewMDGenerator(waitLambda float64, serviceTime float64) *MDGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MDGenerator{}
-	g.ServiceTime = newDeterministicDistr(serviceTime)
-	g.WaitTime = newExponDistr(waitLambda)
+// NewDistGenerator returns a DistGenerator
+func NewDistGenerator(interarrival, service RandDist, d Dispatcher) *DistGenerator {
+	g := &DistGenerator{}
+	g.WaitTime = interarrival
+	g.ServiceTime = service
+	g.dispatcher = d
 	return g
 }
 
-// MDRandGenerator is a exponential waiting time generator that produces fixed service time requests
-// If multiple queues they are fed randomly
-type MDRandGenerator struct {
-	randGenerator
-}
-
-// NewMDRandGenerator returns a MDRandGenerator
-func NewMDRandGenerator(waitLambda float64, serviceTime float64) *MDRandGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MDRandGenerator{}
-	g.WaitTime = newExponDistr(waitLambda)
-	g.ServiceTime = newDeterministicDistr(serviceTime)
-	return g
-}
-
-// MMGenerator is a exponential waiting time generator that produces exponential service time requests
-// If multiple queues they are fed round robin
-type MMGenerator struct {
-	rRGenerator
-}
-
-// NewMMGenerator returns a MMGenerator
-func NewMMGenerator(waitLambda float64, serviceMu float64) *MMGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MMGenerator{}
-	g.ServiceTime = newExponDistr(serviceMu)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+// Run is the main loop of the generator
+func (g *DistGenerator) Run() {
+	for {
+		req := g.Creator.NewRequest(g.ServiceTime.getRand(g.Rand()))
+		g.dispatch(req)
+		g.Wait(g.WaitTime.getRand(g.Rand()))
+	}
 }
 
-// MMRandGenerator is a exponential waiting time generator that produces exponential service time requests
-// If multiple queues they are fed randomly
-type MMRandGenerator struct {
-	randGenerator
+// NewDDGenerator returns a generator with fixed interarrival and service
+// times, feeding multiple queues round robin
+func NewDDGenerator(waitTime, serviceTime float64) *DistGenerator {
+	return NewDistGenerator(newDeterministicDistr(waitTime),
+		newDeterministicDistr(serviceTime), &RoundRobinDispatcher{})
 }
 
-// NewMMRandGenerator returns a MMRandGenerator
-func NewMMRandGenerator(waitLambda float64, serviceMu float64) *MMRandGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MMRandGenerator{}
-	g.ServiceTime = newExponDistr(serviceMu)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+// NewMDGenerator returns a generator with exponential interarrival times and
+// fixed service times, feeding multiple queues round robin
+func NewMDGenerator(waitLambda float64, serviceTime float64) *DistGenerator {
+	return NewDistGenerator(newExponDistr(waitLambda),
+		newDeterministicDistr(serviceTime), &RoundRobinDispatcher{})
 }
 
-//MLNGenerator is exponential waiting time lognormal service time generator
-// If multiple queues they are fed round robin
-type MLNGenerator struct {
-	rRGenerator
+// NewMDRandGenerator returns a generator with exponential interarrival times
+// and fixed service times, feeding multiple queues randomly
+func NewMDRandGenerator(waitLambda float64, serviceTime float64) *DistGenerator {
+	return NewDistGenerator(newExponDistr(waitLambda),
+		newDeterministicDistr(serviceTime), RandomDispatcher{})
 }
 
-// NewMLNGenerator returns an MLNGenerator
-func NewMLNGenerator(waitLambda, mu, sigma float64) *MLNGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MLNGenerator{}
-	g.ServiceTime = newLGDistr(mu, sigma)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+// NewMMGenerator returns a generator with exponential interarrival and
+// service times, feeding multiple queues round robin
+func NewMMGenerator(waitLambda float64, serviceMu float64) *DistGenerator {
+	return NewDistGenerator(newExponDistr(waitLambda),
+		newExponDistr(serviceMu), &RoundRobinDispatcher{})
 }
 
-// MBGenerator is a poisson interarrival generator with
-// requests with bimodal service times (2 values)
-// If multiple queues they are fed roundrobin
-type MBGenerator struct {
-	rRGenerator
+// NewMMRandGenerator returns a generator with exponential interarrival and
+// service times, feeding multiple queues randomly
+func NewMMRandGenerator(waitLambda float64, serviceMu float64) *DistGenerator {
+	return NewDistGenerator(newExponDistr(waitLambda),
+		newExponDistr(serviceMu), RandomDispatcher{})
 }
 
-// NewMBGenerator returns a MBGenerator
-func NewMBGenerator(waitLambda, peak1, peak2, ratio float64) *MBGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MBGenerator{}
-	g.ServiceTime = newBiDistr(peak1, peak2, ratio)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+// NewMLNGenerator returns a generator with exponential interarrival times and
+// lognormal service times, feeding multiple queues round robin
+func NewMLNGenerator(waitLambda, mu, sigma float64) *DistGenerator {
+	return NewDistGenerator(newExponDistr(waitLambda),
+		newLGDistr(mu, sigma), &RoundRobinDispatcher{})
 }
 
-// MBRandGenerator is a poisson interarrival generator with
-// requests with bimodal service times (2 values)
-// If multiple queues they are fed randomly
-type MBRandGenerator struct {
-	randGenerator
+// NewMBGenerator returns a generator with exponential interarrival times and
+// bimodal service times (2 values), feeding multiple queues round robin
+func NewMBGenerator(waitLambda, peak1, peak2, ratio float64) *DistGenerator {
+	return NewDistGenerator(newExponDistr(waitLambda),
+		newBiDistr(peak1, peak2, ratio), &RoundRobinDispatcher{})
 }
 
-// NewMBRandGenerator returns a new MBRandGenerator
-func NewMBRandGenerator(waitLambda, peak1, peak2, ratio float64) *MBRandGenerator {
-	// Seed with time
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	g := &MBRandGenerator{}
-	g.ServiceTime = newBiDistr(peak1, peak2, ratio)
-	g.WaitTime = newExponDistr(waitLambda)
-	return g
+// NewMBRandGenerator returns a generator with exponential interarrival times
+// and bimodal service times (2 values), feeding multiple queues randomly
+func NewMBRandGenerator(waitLambda, peak1, peak2, ratio float64) *DistGenerator {
+	return NewDistGenerator(newExponDistr(waitLambda),
+		newBiDistr(peak1, peak2, ratio), RandomDispatcher{})
 }
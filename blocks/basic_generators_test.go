@@ -0,0 +1,53 @@
+package blocks_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// The dist generator takes the shape of the interarrival times from its
+// interarrival distribution and the arrival rate from lambda
+func TestDistGeneratorRate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		interarrival string
+		lambda       float64
+		err          string // expected error substring, empty if valid
+	}{
+		{"", 0.01, ""},
+		{"deterministic(value=1)", 0.01, ""},
+		{"deterministic(value=500)", 0.02, ""},
+		{"exponential(lambda=3)", 0.01, ""},
+		{"lognormal(mu=2;sigma=1)", 0.01, ""},
+		{"bimodal(v1=1;v2=10;ratio=0.9)", 0.005, ""},
+		{"deterministic(value=0)", 0.01, "cannot be scaled"},
+	}
+	for _, tt := range tests {
+		params := blocks.Params{"lambda": tt.lambda, "service": "deterministic(value=1)"}
+		if tt.interarrival != "" {
+			params["interarrival"] = tt.interarrival
+		}
+		g, err := blocks.NewGenerator("dist", params)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: got error %v, want %q", tt.interarrival, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.interarrival, err)
+			continue
+		}
+		sim, stats := newTestSim(engine.Coroutines)
+		connect(sim, stats, g, []blocks.Processor{rtc()})
+		sim.Run(2000000)
+		rate := stats.GetSummary().Throughput
+		if math.Abs(rate-tt.lambda)/tt.lambda > 0.05 {
+			t.Errorf("%v: %v requests per time unit, want %v", tt.interarrival, rate, tt.lambda)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package blocks
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+func init() {
+	RegisterProcessor("dispatcher", "dispatcher core forwarding requests from a central queue to worker queues",
+		[]Param{ctxCostParam,
+			{"cost", FloatParam, 0.0, "time of every dispatch decision, 0 for an ideal dispatcher"},
+			{"dispatch", DispatcherParam, "jbsq(bound=1)",
+				"dispatch policy, over the requests outstanding at every worker"}},
+		func(p Params) Processor {
+			d := NewCentralDispatcher(p.Float("cost"), p.Dispatcher("dispatch"))
+			d.SetCtxCost(p.Float("ctxCost"))
+			return d
+		})
+}
+
+// CompletionNotifier is implemented by the processors that can notify a
+// dispatcher of the requests they complete
+type CompletionNotifier interface {
+	NotifyCompletions(q engine.QueueInterface, worker int)
+}
+
+// completionNotice tells a dispatcher that a worker completed a request
+type completionNotice struct {
+	worker int
+}
+
+func (n *completionNotice) GetDelay() float64 {
+	return 0
+}
+
+func (n *completionNotice) GetServiceTime() float64 {
+	return 0
+}
+
+func (n *completionNotice) SubServiceTime(t float64) {}
+
+// CentralDispatcher is a dispatcher core that takes the requests of a
+// central queue, its only input queue, and forwards them to the worker
+// queues, its output queues. Every decision takes the dispatch cost, plus
+// the context switch cost as overhead. The workers notify the dispatcher of
+// their completions, so the dispatch policy chooses from the number of
+// requests outstanding at every worker, in service included, rather than
+// from the queue lengths. A policy holding requests, like the default
+// jbsq(bound=1) that only forwards to idle workers, leaves them in the
+// central queue till a worker completes a request.
+type CentralDispatcher struct {
+	genericProcessor
+	cost          float64
+	policy        Dispatcher
+	notices       engine.QueueInterface
+	outstanding   []int
+	notifiers     []int // processors notifying the completions of every worker
+	decisions     int
+	notifications int
+}
+
+// NewCentralDispatcher returns a new *CentralDispatcher
+func NewCentralDispatcher(cost float64, policy Dispatcher) *CentralDispatcher {
+	return &CentralDispatcher{cost: cost, policy: policy}
+}
+
+// noticeQueue is the input queue index of the completion notices, after
+// the central queue
+const noticeQueue = 1
+
+// AddWorker adds a worker queue, served by the given processors, which
+// notify the dispatcher of their completions. The central queue should be
+// added first, with AddInQueue, see Validate.
+func (d *CentralDispatcher) AddWorker(q engine.QueueInterface, procs ...CompletionNotifier) {
+	if d.notices == nil {
+		d.notices = NewQueue()
+		d.AddInQueue(d.notices)
+	}
+	worker := len(d.outstanding)
+	d.outstanding = append(d.outstanding, 0)
+	d.notifiers = append(d.notifiers, len(procs))
+	d.AddOutQueue(q)
+	for _, p := range procs {
+		p.NotifyCompletions(d.notices, worker)
+	}
+}
+
+// Validate checks that the dispatcher has non negative costs, a single
+// central queue added before its workers and at least one worker, and that
+// every worker has a processor notifying its completions, without which the
+// policy would wait for them forever. It should be called once the
+// dispatcher is connected.
+func (d *CentralDispatcher) Validate() error {
+	if d.cost < 0 || d.ctxCost < 0 {
+		return fmt.Errorf("dispatcher: the cost and the ctxCost should not be negative, got %v and %v",
+			d.cost, d.ctxCost)
+	}
+	if len(d.outstanding) == 0 {
+		return fmt.Errorf("dispatcher: no workers")
+	}
+	for worker, n := range d.notifiers {
+		if n == 0 {
+			return fmt.Errorf("dispatcher: no processor serves worker queue %v and notifies its completions", worker)
+		}
+	}
+	if d.GetInQueueCount() != noticeQueue+1 || d.GetInQueue(noticeQueue) != d.notices {
+		return fmt.Errorf("dispatcher: expected a single central queue, added before the workers")
+	}
+	return nil
+}
+
+// Busy returns true if the dispatcher is taking a decision
+func (d *CentralDispatcher) Busy() bool {
+	return d.GetTime() < d.lastEnd
+}
+
+// readNotices accounts for the completions notified by the workers
+func (d *CentralDispatcher) readNotices() {
+	for d.notices.Len() > 0 {
+		n := d.ReadInQueueI(noticeQueue).(*completionNotice)
+		d.outstanding[n.worker]--
+		if _, over := d.WarmupEnd(); over {
+			d.notifications++
+		}
+	}
+}
+
+// Run is the main dispatcher loop
+func (d *CentralDispatcher) Run() {
+	if err := d.Validate(); err != nil {
+		panic(err)
+	}
+	for {
+		d.readNotices()
+		if d.GetInQueueLen(0) == 0 {
+			d.WaitInQueues()
+			continue
+		}
+		worker := d.policy.Choose(d.outstanding, d.Rand())
+		if worker < 0 {
+			// Wait for a completion
+			d.WaitInQueue(noticeQueue)
+			continue
+		}
+		req := d.ReadInQueue()
+		d.serve(d.cost, d.ctxCost)
+		d.outstanding[worker]++
+		if _, over := d.WarmupEnd(); over {
+			d.decisions++
+		}
+		d.WriteOutQueueI(req, worker)
+	}
+}
+
+// DispatchSummary describes the work of a dispatcher during a run
+type DispatchSummary struct {
+	Decisions     int
+	Notifications int
+	Busy          float64 // time taking decisions, overheads included
+	Capacity      float64 // maximum decisions per time unit, +Inf without costs
+}
+
+// DispatchReporter is implemented by the dispatchers, which are reported
+// by DispatcherStats rather than as processors
+type DispatchReporter interface {
+	DispatchSummary() DispatchSummary
+}
+
+// DispatchSummary implements DispatchReporter
+func (d *CentralDispatcher) DispatchSummary() DispatchSummary {
+	u := d.Usage()
+	return DispatchSummary{
+		Decisions:     d.decisions,
+		Notifications: d.notifications,
+		Busy:          u.Busy + u.Overhead,
+		Capacity:      1 / (d.cost + d.ctxCost),
+	}
+}
+
+// DispatcherStats reports the decisions, throughput and utilisation of
+// every dispatcher of the simulation, with the throughput it can sustain.
+// Like UsageStats it covers the run after the warm-up.
+type DispatcherStats struct {
+	sim *engine.Simulation
+}
+
+// NewDispatcherStats returns a new *DispatcherStats
+func NewDispatcherStats() *DispatcherStats {
+	return &DispatcherStats{}
+}
+
+// SetSimulation binds the collector to the simulation whose dispatchers it
+// reports. It is called by the simulation when the collector is registered.
+func (s *DispatcherStats) SetSimulation(sim *engine.Simulation) {
+	s.sim = sim
+}
+
+// HasDispatchers returns true if sim has dispatchers to report
+func HasDispatchers(sim *engine.Simulation) bool {
+	for _, a := range sim.Actors() {
+		if _, ok := a.(DispatchReporter); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DispatcherStats) summaries() []DispatchSummary {
+	var res []DispatchSummary
+	for _, a := range s.sim.Actors() {
+		if r, ok := a.(DispatchReporter); ok {
+			res = append(res, r.DispatchSummary())
+		}
+	}
+	return res
+}
+
+// PrintStats prints the statistics of the dispatchers at the end of the
+// simulation. This is called by the model
+func (s *DispatcherStats) PrintStats(w io.Writer) {
+	elapsed := s.sim.MeasurementTime()
+	fmt.Fprintf(w, "Stats collector: Dispatchers\n")
+	fmt.Fprintf(w, "Dispatcher\tDecisions\tNotifications\tThroughput\tCapacity\tBusy\tUtilisation\n")
+	for i, d := range s.summaries() {
+		fmt.Fprintf(w, "d%v\t%v\t%v\t%v\t%v\t%v\t%v\n", i, d.Decisions, d.Notifications,
+			float64(d.Decisions)/elapsed, d.Capacity, d.Busy, d.Busy/elapsed)
+	}
+}
+
+// Record returns the statistics of the dispatchers as a Record, with metrics
+// prefixed by the dispatcher
+func (s *DispatcherStats) Record() Record {
+	elapsed := s.sim.MeasurementTime()
+	res := Record{Collector: "Dispatchers", Type: "dispatch"}
+	for i, d := range s.summaries() {
+		prefix := fmt.Sprintf("d%v_", i)
+		res.Metrics = append(res.Metrics,
+			Metric{prefix + "decisions", float64(d.Decisions)},
+			Metric{prefix + "notifications", float64(d.Notifications)},
+			Metric{prefix + "throughput", float64(d.Decisions) / elapsed},
+			Metric{prefix + "capacity", d.Capacity},
+			Metric{prefix + "busy", d.Busy},
+			Metric{prefix + "utilisation", d.Busy / elapsed})
+	}
+	return res
+}
@@ -0,0 +1,55 @@
+package blocks
+
+import (
+	"math"
+)
+
+// tQuantiles975 holds the 0.975 quantiles of the Student t distribution
+// for 1 to 30 degrees of freedom
+var tQuantiles975 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447,
+	2.365, 2.306, 2.262, 2.228, 2.201, 2.179, 2.160, 2.145, 2.131, 2.120,
+	2.110, 2.101, 2.093, 2.086, 2.080, 2.074, 2.069, 2.064, 2.060, 2.056,
+	2.052, 2.048, 2.045, 2.042}
+
+// tQuantile975 returns the 0.975 quantile of the Student t distribution
+// with df degrees of freedom
+func tQuantile975(df int) float64 {
+	if df < 1 {
+		return math.Inf(1)
+	}
+	if df <= len(tQuantiles975) {
+		return tQuantiles975[df-1]
+	}
+	// Cornish-Fisher expansion around the normal quantile
+	z := 1.959964
+	d := float64(df)
+	return z + (z*z*z+z)/(4*d) + (5*math.Pow(z, 5)+16*z*z*z+3*z)/(96*d*d)
+}
+
+// MeanCI returns the mean of independent samples and the half width of its
+// 95% confidence interval. The half width is infinite for less than two
+// samples.
+func MeanCI(samples []float64) (mean, halfWidth float64) {
+	n := float64(len(samples))
+	if len(samples) == 0 {
+		return 0, math.Inf(1)
+	}
+	for _, v := range samples {
+		mean += v
+	}
+	mean /= n
+	if len(samples) < 2 {
+		return mean, math.Inf(1)
+	}
+	variance := 0.0
+	for _, v := range samples {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= n - 1
+	return mean, tQuantile975(len(samples)-1) * math.Sqrt(variance/n)
+}
+
+// quantile returns the q quantile of the samples. It sorts a copy of them.
+func quantile(samples []float64, q float64) float64 {
+	return percentilesOf(samples, []float64{q})[0]
+}
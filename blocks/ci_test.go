@@ -0,0 +1,39 @@
+package blocks_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+func TestMeanCI(t *testing.T) {
+	// 61 samples, 31 ones and 30 zeros, whose standard error is 0.0645410
+	var many []float64
+	for i := 0; i < 61; i++ {
+		many = append(many, float64((i+1)%2))
+	}
+	tests := []struct {
+		name            string
+		samples         []float64
+		mean, halfWidth float64
+	}{
+		{"none", nil, 0, math.Inf(1)},
+		{"one", []float64{5}, 5, math.Inf(1)},
+		{"constant", []float64{2, 2, 2, 2}, 2, 0},
+		// t quantile with 2 degrees of freedom 4.303, standard error 1/sqrt(3)
+		{"few", []float64{1, 2, 3}, 2, 4.303 / math.Sqrt(3)},
+		// t quantile with 60 degrees of freedom 2.000298
+		{"many", many, 31.0 / 61, 2.000298 * 0.0645410},
+	}
+	for _, tt := range tests {
+		mean, hw := blocks.MeanCI(tt.samples)
+		if math.Abs(mean-tt.mean) > 1e-9 {
+			t.Errorf("%v: mean %v, want %v", tt.name, mean, tt.mean)
+		}
+		if math.IsInf(tt.halfWidth, 1) != math.IsInf(hw, 1) ||
+			math.Abs(hw-tt.halfWidth) > 1e-4*tt.halfWidth {
+			t.Errorf("%v: half width %v, want %v", tt.name, hw, tt.halfWidth)
+		}
+	}
+}
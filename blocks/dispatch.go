@@ -0,0 +1,269 @@
+package blocks
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+func init() {
+	RegisterDispatcher("random", "a random queue", nil,
+		func(p Params) (Dispatcher, error) {
+			return RandomDispatcher{}, nil
+		})
+	RegisterDispatcher("roundrobin", "every queue in turn", nil,
+		func(p Params) (Dispatcher, error) {
+			return &RoundRobinDispatcher{}, nil
+		})
+	RegisterDispatcher("jsq", "join the shortest queue", nil,
+		func(p Params) (Dispatcher, error) {
+			return JSQDispatcher{}, nil
+		})
+	RegisterDispatcher("pod", "power of d choices, the shortest of d random queues",
+		[]Param{{"d", IntParam, 2, "number of sampled queues"}},
+		func(p Params) (Dispatcher, error) {
+			if p.Int("d") < 1 {
+				return nil, fmt.Errorf("pod: d must be at least 1, got %v", p.Int("d"))
+			}
+			return PowerOfDDispatcher{p.Int("d")}, nil
+		})
+	RegisterDispatcher("jiq", "join an idle worker, a random one if none", nil,
+		func(p Params) (Dispatcher, error) {
+			return JIQDispatcher{}, nil
+		})
+	RegisterDispatcher("jbsq", "join the least loaded worker with less than bound requests, hold the request if none (the least loaded worker for generators)",
+		[]Param{{"bound", IntParam, 2, "requests per worker, in service included"}},
+		func(p Params) (Dispatcher, error) {
+			if p.Int("bound") < 1 {
+				return nil, fmt.Errorf("jbsq: bound must be at least 1, got %v", p.Int("bound"))
+			}
+			return JBSQDispatcher{p.Int("bound")}, nil
+		})
+}
+
+// Dispatcher chooses the output queue of a request from the load of every
+// output queue. Generators give the queue lengths, which count the waiting
+// requests but not the ones being served, unless the dispatcher is a
+// WorkerDispatcher. The central dispatcher gives the requests outstanding at
+// every worker, in service included. It returns -1 to hold the request, which
+// only the central dispatcher can do.
+type Dispatcher interface {
+	Choose(lens []int, r *rand.Rand) int
+}
+
+// WorkerDispatcher is implemented by the dispatch policies that need to
+// know which workers are idle, so choose from the requests outstanding at
+// every worker, in service included, rather than from the queue lengths
+type WorkerDispatcher interface {
+	Dispatcher
+	workerOnly()
+}
+
+// ServiceReporter is implemented by the processors that can tell how many
+// requests they are serving
+type ServiceReporter interface {
+	InService() int
+}
+
+// WorkerWatcher is implemented by the generators that can dispatch with a
+// WorkerDispatcher, from the length of their output queues and the requests
+// served by the processors of every queue
+type WorkerWatcher interface {
+	WatchWorkers(q engine.QueueInterface, servers ...ServiceReporter)
+	// ValidateWorkers returns an error if the dispatch policy needs the
+	// servers of an output queue that has none
+	ValidateWorkers() error
+}
+
+// ParseDispatcher parses a dispatch policy given as name or
+// name(param=value;...), with a registered dispatcher
+func ParseDispatcher(s string) (Dispatcher, error) {
+	name, params, err := parseComponent(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad dispatcher %q: %v", s, err)
+	}
+	return NewDispatcher(name, params)
+}
+
+// DispatchSetter is implemented by the generators whose dispatch policy can
+// be selected
+type DispatchSetter interface {
+	SetDispatcher(d Dispatcher)
+}
+
+// RandomDispatcher sends every request to a random queue
+type RandomDispatcher struct{}
+
+// Choose implements Dispatcher
+func (RandomDispatcher) Choose(lens []int, r *rand.Rand) int {
+	return r.Intn(len(lens))
+}
+
+// RoundRobinDispatcher sends requests to every queue in turn
+type RoundRobinDispatcher struct {
+	count int
+}
+
+// Choose implements Dispatcher
+func (d *RoundRobinDispatcher) Choose(lens []int, r *rand.Rand) int {
+	res := d.count % len(lens)
+	d.count++
+	return res
+}
+
+// shortest returns the index of a shortest queue among the candidates, or
+// -1 if there are none. Ties are broken at random.
+func shortest(lens []int, candidates []int, r *rand.Rand) int {
+	res, ties := -1, 0
+	for _, i := range candidates {
+		switch {
+		case res < 0 || lens[i] < lens[res]:
+			res, ties = i, 1
+		case lens[i] == lens[res]:
+			// Reservoir sampling among the ties
+			ties++
+			if r.Intn(ties) == 0 {
+				res = i
+			}
+		}
+	}
+	return res
+}
+
+func allQueues(lens []int) []int {
+	res := make([]int, len(lens))
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
+
+// JSQDispatcher sends every request to the shortest queue
+type JSQDispatcher struct{}
+
+// Choose implements Dispatcher
+func (JSQDispatcher) Choose(lens []int, r *rand.Rand) int {
+	return shortest(lens, allQueues(lens), r)
+}
+
+// PowerOfDDispatcher sends every request to the shortest of D distinct
+// queues sampled at random
+type PowerOfDDispatcher struct {
+	D int
+}
+
+// Choose implements Dispatcher
+func (d PowerOfDDispatcher) Choose(lens []int, r *rand.Rand) int {
+	sampled := r.Perm(len(lens))
+	if d.D > 0 && d.D < len(sampled) {
+		sampled = sampled[:d.D]
+	}
+	return shortest(lens, sampled, r)
+}
+
+// JIQDispatcher sends every request to a random idle worker, or to a random
+// worker if none is idle. It is a WorkerDispatcher.
+type JIQDispatcher struct{}
+
+func (JIQDispatcher) workerOnly() {}
+
+// Choose implements Dispatcher
+func (JIQDispatcher) Choose(lens []int, r *rand.Rand) int {
+	var empty []int
+	for i, l := range lens {
+		if l == 0 {
+			empty = append(empty, i)
+		}
+	}
+	if len(empty) == 0 {
+		return r.Intn(len(lens))
+	}
+	return empty[r.Intn(len(empty))]
+}
+
+// JBSQDispatcher sends every request to the least loaded worker with less
+// than Bound outstanding requests, and holds it while all the workers have
+// Bound requests or more. Generators cannot hold requests, so they send them
+// to the least loaded worker instead. It is a WorkerDispatcher.
+type JBSQDispatcher struct {
+	Bound int
+}
+
+func (JBSQDispatcher) workerOnly() {}
+
+// Choose implements Dispatcher
+func (d JBSQDispatcher) Choose(lens []int, r *rand.Rand) int {
+	var open []int
+	for i, l := range lens {
+		if l < d.Bound {
+			open = append(open, i)
+		}
+	}
+	return shortest(lens, open, r)
+}
+
+// dispatchingGenerator is a generator whose requests are sent to the output
+// queues by a Dispatcher. A WorkerDispatcher chooses from the queue lengths
+// plus the requests served by the processors of every queue, see
+// WatchWorkers, and the least loaded queue replaces the requests it holds.
+type dispatchingGenerator struct {
+	genericGenerator
+	dispatcher Dispatcher
+	servers    map[engine.QueueInterface][]ServiceReporter
+}
+
+// SetDispatcher selects the dispatch policy of the generator
+func (g *dispatchingGenerator) SetDispatcher(d Dispatcher) {
+	g.dispatcher = d
+}
+
+// WatchWorkers implements WorkerWatcher
+func (g *dispatchingGenerator) WatchWorkers(q engine.QueueInterface, servers ...ServiceReporter) {
+	if g.servers == nil {
+		g.servers = make(map[engine.QueueInterface][]ServiceReporter)
+	}
+	g.servers[q] = append(g.servers[q], servers...)
+}
+
+// ValidateWorkers implements WorkerWatcher
+func (g *dispatchingGenerator) ValidateWorkers() error {
+	if _, ok := g.dispatcher.(WorkerDispatcher); !ok {
+		return nil
+	}
+	for i := 0; i < g.GetOutQueueCount(); i++ {
+		if len(g.servers[g.GetOutQueue(i)]) == 0 {
+			return fmt.Errorf("the dispatch policy needs to know the processors serving output queue %v", i)
+		}
+	}
+	return nil
+}
+
+// loads returns the load of every output queue for the dispatcher
+func (g *dispatchingGenerator) loads() []int {
+	lens := g.GetAllOutQueueLens()
+	if _, ok := g.dispatcher.(WorkerDispatcher); !ok {
+		return lens
+	}
+	res := make([]int, len(lens))
+	for i, l := range lens {
+		res[i] = l
+		for _, s := range g.servers[g.GetOutQueue(i)] {
+			res[i] += s.InService()
+		}
+	}
+	return res
+}
+
+// dispatch sends req to the queue chosen by the dispatcher
+func (g *dispatchingGenerator) dispatch(req engine.ReqInterface) {
+	loads := g.loads()
+	qIdx := g.dispatcher.Choose(loads, g.Rand())
+	if qIdx < 0 {
+		qIdx = shortest(loads, allQueues(loads), g.Rand())
+	}
+	if monitorReq, ok := req.(*MonitorReq); ok {
+		monitorReq.initLength = g.GetOutQueueLen(qIdx)
+	}
+	g.WriteOutQueueI(req, qIdx)
+}
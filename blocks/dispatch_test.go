@@ -0,0 +1,163 @@
+package blocks_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+func TestDispatchers(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		policy string
+		lens   []int
+		want   []int // possible choices, -1 to hold
+	}{
+		{"jsq", []int{3, 1, 2}, []int{1}},
+		{"jsq", []int{2, 0, 0}, []int{1, 2}},
+		{"pod(d=3)", []int{3, 1, 2}, []int{1}},
+		{"pod(d=1)", []int{3, 1, 2}, []int{0, 1, 2}},
+		{"random", []int{0, 0}, []int{0, 1}},
+		{"jiq", []int{1, 0, 2, 0}, []int{1, 3}},
+		{"jiq", []int{1, 1}, []int{0, 1}},
+		{"jbsq(bound=2)", []int{2, 1, 3}, []int{1}},
+		{"jbsq(bound=2)", []int{2, 2}, []int{-1}},
+		{"jbsq(bound=1)", []int{1, 0, 0}, []int{1, 2}},
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		d, err := blocks.ParseDispatcher(tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			got := d.Choose(tt.lens, r)
+			ok := false
+			for _, w := range tt.want {
+				ok = ok || got == w
+			}
+			if !ok {
+				t.Errorf("%v over %v: got %v, want one of %v", tt.policy, tt.lens, got, tt.want)
+				break
+			}
+		}
+	}
+	rr, _ := blocks.ParseDispatcher("roundrobin")
+	for i := 0; i < 6; i++ {
+		if got := rr.Choose([]int{5, 0, 0}, r); got != i%3 {
+			t.Errorf("roundrobin: request %v went to %v", i, got)
+		}
+	}
+}
+
+func TestParseDispatcher(t *testing.T) {
+	tests := []struct {
+		s   string
+		err string // expected error substring, empty if valid
+	}{
+		{"jsq", ""},
+		{"pod", ""},
+		{"pod(d=3)", ""},
+		{"jbsq(bound=1)", ""},
+		{"pod(d=0)", "d must be at least 1"},
+		{"jbsq(bound=0)", "bound must be at least 1"},
+		{"jbsq(bound=x)", "bound"},
+		{"pod(k=2)", "k"},
+		{"pod(d=2", "missing )"},
+		{"lwl", "lwl"},
+	}
+	for _, tt := range tests {
+		_, err := blocks.ParseDispatcher(tt.s)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%v: unexpected error %v", tt.s, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%v: got error %v, want %q", tt.s, err, tt.err)
+		}
+	}
+}
+
+// Ties are broken uniformly at random
+func TestDispatcherTies(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, policy := range []string{"jsq", "pod(d=4)", "jiq"} {
+		d, err := blocks.ParseDispatcher(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := make([]int, 4)
+		const n = 4000
+		for i := 0; i < n; i++ {
+			counts[d.Choose([]int{0, 0, 0, 0}, r)]++
+		}
+		for q, c := range counts {
+			if c < n/4*9/10 || c > n/4*11/10 {
+				t.Errorf("%v: queue %v chosen %v times out of %v", policy, q, c, n)
+			}
+		}
+	}
+}
+
+// multiQueueDispatch runs a generator dispatching to one queue per core,
+// each with its run to completion processor, and returns the summary of the
+// drain
+func multiQueueDispatch(t *testing.T, policy string, lambda, mu float64, cores int) blocks.Summary {
+	d, err := blocks.ParseDispatcher(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, stats := newTestSim(engine.Coroutines)
+	g := blocks.NewMMRandGenerator(lambda, mu)
+	g.SetDispatcher(d)
+	servers := make([][]blocks.Processor, cores)
+	for i := range servers {
+		servers[i] = []blocks.Processor{rtc()}
+	}
+	for i, q := range connect(sim, stats, g, servers...) {
+		g.WatchWorkers(q, servers[i][0].(blocks.ServiceReporter))
+	}
+	if err := g.ValidateWorkers(); err != nil {
+		t.Fatal(err)
+	}
+	sim.Run(200000)
+	return stats.GetSummary()
+}
+
+// Generators see the requests in service with the worker dispatchers, so
+// jiq and jbsq should leave requests waiting behind a busy worker much less
+// often than random dispatch
+func TestGeneratorWorkerDispatch(t *testing.T) {
+	t.Parallel()
+	// 50% load over 4 cores
+	random := multiQueueDispatch(t, "random", 0.04, 0.02, 4)
+	for _, policy := range []string{"jiq", "jbsq(bound=1)", "jbsq(bound=2)"} {
+		sum := multiQueueDispatch(t, policy, 0.04, 0.02, 4)
+		if sum.QueueDelay > random.QueueDelay/2 {
+			t.Errorf("%v: queueing delay %v, random %v", policy, sum.QueueDelay, random.QueueDelay)
+		}
+	}
+
+	g := blocks.NewMMRandGenerator(0.04, 0.02)
+	jiq, _ := blocks.ParseDispatcher("jiq")
+	g.SetDispatcher(jiq)
+	g.AddOutQueue(blocks.NewQueue())
+	if err := g.ValidateWorkers(); err == nil {
+		t.Error("jiq without the servers of the queues: expected an error")
+	}
+}
+
+// Queue length aware policies balance the queues better than random
+// dispatch, and looking at more queues helps
+func TestGeneratorQueueDispatch(t *testing.T) {
+	t.Parallel()
+	// 70% load over 4 cores
+	random := multiQueueDispatch(t, "random", 0.056, 0.02, 4)
+	pod := multiQueueDispatch(t, "pod(d=2)", 0.056, 0.02, 4)
+	jsq := multiQueueDispatch(t, "jsq", 0.056, 0.02, 4)
+	if !(jsq.Avg < pod.Avg && pod.Avg < random.Avg/1.5) {
+		t.Errorf("latency: jsq %v, pod(d=2) %v, random %v", jsq.Avg, pod.Avg, random.Avg)
+	}
+}
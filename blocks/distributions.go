@@ -1,12 +1,74 @@
 package blocks
 
 import (
+	"fmt"
 	"math"
 	"math/rand"
 )
 
-type randDist interface {
-	getRand() float64
+func init() {
+	RegisterDistribution("deterministic", "always the same value",
+		[]Param{{"value", FloatParam, nil, "the value"}},
+		func(p Params) RandDist {
+			return newDeterministicDistr(p.Float("value"))
+		})
+	RegisterDistribution("exponential", "exponential with the given rate",
+		[]Param{{"lambda", FloatParam, nil, "rate, the mean is 1/lambda"}},
+		func(p Params) RandDist {
+			return newExponDistr(p.Float("lambda"))
+		})
+	RegisterDistribution("lognormal", "lognormal",
+		[]Param{{"mu", FloatParam, nil, "mean of the underlying normal"},
+			{"sigma", FloatParam, nil, "stddev of the underlying normal"}},
+		func(p Params) RandDist {
+			return newLGDistr(p.Float("mu"), p.Float("sigma"))
+		})
+	RegisterDistribution("bimodal", "one of two values",
+		[]Param{{"v1", FloatParam, nil, "first value"},
+			{"v2", FloatParam, nil, "second value"},
+			{"ratio", FloatParam, nil, "probability of the first value"}},
+		func(p Params) RandDist {
+			return newBiDistr(p.Float("v1"), p.Float("v2"), p.Float("ratio"))
+		})
+}
+
+// ParseDistribution parses a distribution given as name or
+// name(param=value;...), with a registered distribution
+func ParseDistribution(s string) (RandDist, error) {
+	name, params, err := parseComponent(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad distribution %q: %v", s, err)
+	}
+	return NewDistribution(name, params)
+}
+
+// RandDist is a distribution that draws its samples from the given random
+// stream, so that the stream and not the distribution owns the randomness
+type RandDist interface {
+	getRand(r *rand.Rand) float64
+	mean() float64
+}
+
+// Scaled distribution, the samples of d times factor
+type scaledDistr struct {
+	d      RandDist
+	factor float64
+}
+
+// withMean returns d scaled to the given mean
+func withMean(d RandDist, mean float64) (RandDist, error) {
+	if !(d.mean() > 0) || math.IsInf(d.mean(), 1) {
+		return nil, fmt.Errorf("distribution mean %v cannot be scaled", d.mean())
+	}
+	return &scaledDistr{d, mean / d.mean()}, nil
+}
+
+func (distr *scaledDistr) getRand(r *rand.Rand) float64 {
+	return distr.factor * distr.d.getRand(r)
+}
+
+func (distr *scaledDistr) mean() float64 {
+	return distr.factor * distr.d.mean()
 }
 
 // Deterministic Distribution
@@ -18,7 +80,11 @@ func newDeterministicDistr(d float64) *deterministicDistr {
 	return &deterministicDistr{d}
 }
 
-func (distr *deterministicDistr) getRand() float64 {
+func (distr *deterministicDistr) getRand(r *rand.Rand) float64 {
+	return distr.d
+}
+
+func (distr *deterministicDistr) mean() float64 {
 	return distr.d
 }
 
@@ -31,8 +97,12 @@ func newExponDistr(l float64) *exponDistr {
 	return &exponDistr{l}
 }
 
-func (distr *exponDistr) getRand() float64 {
-	return float64(rand.ExpFloat64() / distr.lambda)
+func (distr *exponDistr) getRand(r *rand.Rand) float64 {
+	return float64(r.ExpFloat64() / distr.lambda)
+}
+
+func (distr *exponDistr) mean() float64 {
+	return 1 / distr.lambda
 }
 
 // LogNormal Distribution
@@ -45,12 +115,16 @@ func newLGDistr(mu, sigma float64) *lGDistr {
 	return &lGDistr{mu, sigma}
 }
 
-func (distr *lGDistr) getRand() float64 {
-	z := rand.NormFloat64()
+func (distr *lGDistr) getRand(r *rand.Rand) float64 {
+	z := r.NormFloat64()
 	s := math.Exp(distr.mu + distr.sigma*z)
 	return s
 }
 
+func (distr *lGDistr) mean() float64 {
+	return math.Exp(distr.mu + distr.sigma*distr.sigma/2)
+}
+
 // Bimodel Distribution
 type biDistr struct {
 	v1    float64
@@ -62,9 +136,13 @@ func newBiDistr(v1, v2, ratio float64) *biDistr {
 	return &biDistr{v1, v2, ratio}
 }
 
-func (distr *biDistr) getRand() float64 {
-	if rand.Float64() > distr.ratio {
+func (distr *biDistr) getRand(r *rand.Rand) float64 {
+	if r.Float64() > distr.ratio {
 		return distr.v2
 	}
 	return distr.v1
 }
+
+func (distr *biDistr) mean() float64 {
+	return distr.ratio*distr.v1 + (1-distr.ratio)*distr.v2
+}
@@ -0,0 +1,42 @@
+package blocks_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+func TestParseDistribution(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		s   string
+		err string // expected error substring, empty if valid
+	}{
+		{"deterministic(value=1)", ""},
+		{"exponential(lambda=0.02)", ""},
+		{"exponential( lambda = 0.02 )", ""},
+		{"lognormal(mu=2;sigma=1)", ""},
+		{"bimodal(v1=1;v2=10;ratio=0.9)", ""},
+		{"pareto(alpha=1)", "unknown distribution: pareto"},
+		{"exponential", "missing parameter lambda"},
+		{"exponential()", "missing parameter lambda"},
+		{"lognormal(mu=2)", "missing parameter sigma"},
+		{"exponential(lambda=0.02", "missing )"},
+		{"exponential(lambda)", "expected name=value"},
+		{"exponential(lambda=fast)", "parameter lambda"},
+		{"exponential(lambda=1;mu=2)", "unknown parameter mu"},
+	}
+	for _, tt := range tests {
+		d, err := blocks.ParseDistribution(tt.s)
+		if tt.err == "" {
+			if err != nil || d == nil {
+				t.Errorf("%v: got %v, %v, want a distribution", tt.s, d, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want %q", tt.s, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+)
+
+// zeroThreshold is the largest value counted as zero by a Histogram
+const zeroThreshold = 1e-9
+
+// Histogram is a histogram with logarithmic buckets. Every quantile it
+// returns is within its relative error of the exact quantile of the samples,
+// whatever their range, and its size only grows with the logarithm of the
+// range. The minimum and maximum are exact. Histograms with the same relative
+// error can be merged, e.g. to combine processors or replications.
+type Histogram struct {
+	relErr   float64
+	gamma    float64 // bucket i holds the values in (gamma^(i-1), gamma^i]
+	logGamma float64
+	offset   int // index of buckets[0]
+	buckets  []uint64
+	zeros    uint64 // values below zeroThreshold
+
+	count     uint64
+	sum       float64
+	sumSquare float64
+	min       float64
+	max       float64
+}
+
+// NewHistogram returns an empty histogram with the given relative error,
+// which should be strictly between 0 and 1
+func NewHistogram(relErr float64) (*Histogram, error) {
+	if !(relErr > 0 && relErr < 1) {
+		return nil, fmt.Errorf("the relative error should be between 0 and 1, got %v", relErr)
+	}
+	gamma := (1 + relErr) / (1 - relErr)
+	return &Histogram{
+		relErr:   relErr,
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}, nil
+}
+
+// RelativeError returns the relative error of the quantiles
+func (h *Histogram) RelativeError() float64 {
+	return h.relErr
+}
+
+func (h *Histogram) bucket(v float64) int {
+	return int(math.Ceil(math.Log(v) / h.logGamma))
+}
+
+// value returns the value that represents bucket i, within the relative
+// error of every value of the bucket
+func (h *Histogram) value(i int) float64 {
+	return 2 * math.Pow(h.gamma, float64(i)) / (h.gamma + 1)
+}
+
+// addBuckets adds n samples to bucket i, growing the buckets as needed
+func (h *Histogram) addBuckets(i int, n uint64) {
+	if len(h.buckets) == 0 {
+		h.offset = i
+	}
+	if i < h.offset {
+		grown := make([]uint64, len(h.buckets)+h.offset-i)
+		copy(grown[h.offset-i:], h.buckets)
+		h.buckets = grown
+		h.offset = i
+	}
+	for i-h.offset >= len(h.buckets) {
+		h.buckets = append(h.buckets, 0)
+	}
+	h.buckets[i-h.offset] += n
+}
+
+// Add adds a sample. Negative samples are counted as zero.
+func (h *Histogram) Add(v float64) {
+	if v < zeroThreshold {
+		h.zeros++
+	} else {
+		h.addBuckets(h.bucket(v), 1)
+	}
+	h.count++
+	h.sum += v
+	h.sumSquare += v * v
+	h.min = math.Min(h.min, v)
+	h.max = math.Max(h.max, v)
+}
+
+// Merge adds the samples of o to h. Both should have the same relative
+// error.
+func (h *Histogram) Merge(o *Histogram) error {
+	if h.relErr != o.relErr {
+		return fmt.Errorf("cannot merge histograms with relative errors %v and %v", h.relErr, o.relErr)
+	}
+	for i, n := range o.buckets {
+		if n > 0 {
+			h.addBuckets(o.offset+i, n)
+		}
+	}
+	h.zeros += o.zeros
+	h.count += o.count
+	h.sum += o.sum
+	h.sumSquare += o.sumSquare
+	h.min = math.Min(h.min, o.min)
+	h.max = math.Max(h.max, o.max)
+	return nil
+}
+
+// Count returns the number of samples
+func (h *Histogram) Count() int {
+	return int(h.count)
+}
+
+// Mean returns the exact mean of the samples
+func (h *Histogram) Mean() float64 {
+	return h.sum / float64(h.count)
+}
+
+// StdDev returns the standard deviation of the samples
+func (h *Histogram) StdDev() float64 {
+	mean := h.Mean()
+	return math.Sqrt(math.Max(0, h.sumSquare/float64(h.count)-mean*mean))
+}
+
+// Quantile returns the q quantile of the samples, 0 and 1 being the exact
+// minimum and maximum. It returns zero if there are no samples.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+	if q >= 1 {
+		return h.max
+	}
+	// Same rank as the quantiles of the sorted samples
+	rank := uint64(float64(h.count) * q)
+	if rank < h.zeros {
+		return math.Max(h.min, 0)
+	}
+	seen := h.zeros
+	for i, n := range h.buckets {
+		seen += n
+		if seen > rank {
+			// Clamp to the exact range of the samples
+			return math.Min(math.Max(h.value(h.offset+i), h.min), h.max)
+		}
+	}
+	return h.max
+}
+
+// Quantiles returns the given quantiles of the samples
+func (h *Histogram) Quantiles(quantiles []float64) []float64 {
+	res := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		res[i] = h.Quantile(q)
+	}
+	return res
+}
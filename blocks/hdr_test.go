@@ -0,0 +1,89 @@
+package blocks_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// exactQuantile returns the q quantile of sorted samples, with the rank of
+// the collectors
+func exactQuantile(sorted []float64, q float64) float64 {
+	idx := int(float64(len(sorted)) * q)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// Histogram quantiles should be within the relative error of the exact
+// ones, over samples spanning several orders of magnitude
+func TestHistogramRelativeError(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	samples := make([]float64, 200000)
+	for i := range samples {
+		samples[i] = math.Exp(r.NormFloat64() * 3)
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	quantiles := []float64{0, 0.001, 0.1, 0.5, 0.9, 0.99, 0.999, 0.9999, 1}
+
+	for _, relErr := range []float64{0.1, 0.01, 0.001} {
+		h, err := blocks.NewHistogram(relErr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Merged halves should give the same quantiles as a single histogram
+		other, _ := blocks.NewHistogram(relErr)
+		for i, v := range samples {
+			if i%2 == 0 {
+				h.Add(v)
+			} else {
+				other.Add(v)
+			}
+		}
+		if err := h.Merge(other); err != nil {
+			t.Fatal(err)
+		}
+		if h.Count() != len(samples) {
+			t.Fatalf("relErr %v: count %v, want %v", relErr, h.Count(), len(samples))
+		}
+		for _, q := range quantiles {
+			got, want := h.Quantile(q), exactQuantile(sorted, q)
+			if err := math.Abs(got-want) / want; err > relErr*(1+1e-9) {
+				t.Errorf("relErr %v: quantile %v: got %v, want %v (error %.3g)", relErr, q, got, want, err)
+			}
+		}
+	}
+}
+
+func TestHistogramRelativeErrorBounds(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		relErr float64
+		valid  bool
+	}{
+		{-0.1, false},
+		{0, false},
+		{1e-6, true},
+		{0.5, true},
+		{0.999, true},
+		{1, false},
+		{1.5, false},
+		{math.NaN(), false},
+	}
+	for _, tt := range tests {
+		_, err := blocks.NewHistogram(tt.relErr)
+		if (err == nil) != tt.valid {
+			t.Errorf("histogram with relErr %v: got error %v, want valid %v", tt.relErr, err, tt.valid)
+		}
+		_, err = blocks.NewDrain("book", blocks.Params{"relErr": tt.relErr})
+		if (err == nil) != tt.valid {
+			t.Errorf("book drain with relErr %v: got error %v, want valid %v", tt.relErr, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package blocks_test
+
+import (
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// newTestSim returns a simulation in the given execution mode, with a fixed
+// seed and an AllKeeper collecting its statistics
+func newTestSim(mode engine.ExecMode) (*engine.Simulation, *blocks.AllKeeper) {
+	sim := engine.InitSim(1)
+	sim.SetExecMode(mode)
+	stats := &blocks.AllKeeper{}
+	sim.InitStats(stats)
+	return sim, stats
+}
+
+// connect makes g feed one new queue per element of servers, served by its
+// processors, which drain to stats. g creates simple requests. It registers
+// the processors, then g, and returns the queues.
+func connect(sim *engine.Simulation, stats blocks.RequestDrain, g blocks.Generator,
+	servers ...[]blocks.Processor) []engine.QueueInterface {
+	queues := make([]engine.QueueInterface, len(servers))
+	for i, procs := range servers {
+		queues[i] = blocks.NewQueue()
+		for _, p := range procs {
+			p.AddInQueue(queues[i])
+			p.SetReqDrain(stats)
+			sim.RegisterActor(p)
+		}
+		g.AddOutQueue(queues[i])
+	}
+	g.SetCreator(blocks.NewSimpleReqCreator(sim))
+	sim.RegisterActor(g)
+	return queues
+}
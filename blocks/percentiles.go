@@ -0,0 +1,110 @@
+package blocks
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultPercentiles are reported by the collectors unless configured
+var defaultPercentiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// defaultPercentileNames is defaultPercentiles as accepted by ParsePercentiles
+var defaultPercentileNames = []string{"p50", "p90", "p95", "p99"}
+
+// percentilesParam is the parameter of the drains reporting percentiles
+var percentilesParam = Param{"percentiles", PercentilesParam, defaultPercentileNames,
+	"reported percentiles, like p50, p99.9, min or max"}
+
+// ParsePercentile parses a percentile given as pX with 0 < X < 100, min or
+// max, and returns it as a quantile between 0 and 1
+func ParsePercentile(s string) (float64, error) {
+	switch s {
+	case "min":
+		return 0, nil
+	case "max":
+		return 1, nil
+	}
+	p, err := strconv.ParseFloat(strings.TrimPrefix(s, "p"), 64)
+	if err != nil || !strings.HasPrefix(s, "p") || p <= 0 || p >= 100 {
+		return 0, fmt.Errorf("bad percentile: %v", s)
+	}
+	return p / 100, nil
+}
+
+// ParsePercentiles parses a list of percentiles, see ParsePercentile
+func ParsePercentiles(list []string) ([]float64, error) {
+	res := make([]float64, len(list))
+	for i, s := range list {
+		q, err := ParsePercentile(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		res[i] = q
+	}
+	return res, nil
+}
+
+// DefaultPercentiles returns the percentiles reported unless configured, as
+// quantiles between 0 and 1
+func DefaultPercentiles() []float64 {
+	return append([]float64(nil), defaultPercentiles...)
+}
+
+// PercentileSetter is implemented by the collectors reporting percentiles
+type PercentileSetter interface {
+	SetPercentiles(quantiles []float64)
+}
+
+// PercentileName returns the name of quantile q as accepted by
+// ParsePercentile
+func PercentileName(q float64) string {
+	switch q {
+	case 0:
+		return "min"
+	case 1:
+		return "max"
+	}
+	return "p" + strconv.FormatFloat(math.Round(q*1e8)/1e6, 'g', -1, 64)
+}
+
+// percentileHeader returns the column header of quantile q in the text output
+func percentileHeader(q float64) string {
+	name := PercentileName(q)
+	if q == 0 || q == 1 {
+		return name
+	}
+	return name[1:] + "th"
+}
+
+// percentilesOf returns the given quantiles of the samples. It sorts a copy
+// of them.
+func percentilesOf(samples []float64, quantiles []float64) []float64 {
+	res := make([]float64, len(quantiles))
+	if len(samples) == 0 {
+		return res
+	}
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+	for i, q := range quantiles {
+		idx := int(float64(len(sorted)) * q)
+		if idx >= len(sorted) {
+			idx = len(sorted) - 1
+		}
+		res[i] = sorted[idx]
+	}
+	return res
+}
+
+// percentileMetrics names the values of the quantiles, with an optional
+// prefix
+func percentileMetrics(prefix string, quantiles, values []float64) Metrics {
+	res := make(Metrics, len(quantiles))
+	for i, q := range quantiles {
+		res[i] = Metric{prefix + PercentileName(q), values[i]}
+	}
+	return res
+}
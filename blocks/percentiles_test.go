@@ -0,0 +1,88 @@
+package blocks_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+func TestParsePercentile(t *testing.T) {
+	tests := []struct {
+		s    string
+		q    float64
+		name string // PercentileName of q, empty if s is invalid
+	}{
+		{"p50", 0.5, "p50"},
+		{"p99", 0.99, "p99"},
+		{"p99.9", 0.999, "p99.9"},
+		{"p99.99", 0.9999, "p99.99"},
+		{"min", 0, "min"},
+		{"max", 1, "max"},
+		{"p0", 0, ""},
+		{"p100", 0, ""},
+		{"50", 0, ""},
+		{"px", 0, ""},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		q, err := blocks.ParsePercentile(tt.s)
+		if tt.name == "" {
+			if err == nil {
+				t.Errorf("%q: got %v, want an error", tt.s, q)
+			}
+			continue
+		}
+		if err != nil || math.Abs(q-tt.q) > 1e-12 {
+			t.Errorf("%q: got %v, %v, want %v", tt.s, q, err, tt.q)
+		}
+		if name := blocks.PercentileName(q); name != tt.name {
+			t.Errorf("%q: named %v, want %v", tt.s, name, tt.name)
+		}
+	}
+}
+
+// Requests served alone have a slowdown of 1, and their latency percentiles
+// are their service time percentiles
+func TestSelectedPercentiles(t *testing.T) {
+	t.Parallel()
+	g, err := blocks.NewGenerator("dist", blocks.Params{"lambda": 0.01,
+		"interarrival": "deterministic(value=1)", "service": "bimodal(v1=10;v2=30;ratio=0.8)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, err := blocks.ParsePercentiles([]string{"min", "p50", "p90", "max"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, stats := newTestSim(engine.Coroutines)
+	stats.SetPercentiles(qs)
+	connect(sim, stats, g, []blocks.Processor{rtc()})
+	sim.Run(100000)
+
+	sum := stats.GetSummary()
+	want := []struct {
+		name  string
+		value float64
+	}{{"min", 10}, {"p50", 10}, {"p90", 30}, {"max", 30}}
+	if len(sum.Percentiles) != len(want) {
+		t.Fatalf("percentiles %v, want %v", sum.Percentiles, want)
+	}
+	for i, w := range want {
+		if m := sum.Percentiles[i]; m.Name != w.name || m.Value != w.value {
+			t.Errorf("percentile %v: got %v, want %v", i, m, w)
+		}
+		if m := sum.SlowdownPercentiles[i]; m.Name != w.name || m.Value != 1 {
+			t.Errorf("slowdown percentile %v: got %v, want %v 1", i, m, w.name)
+		}
+	}
+	if sum.Slowdown != 1 || sum.QueueDelay != 0 || sum.QueueDelayPercentiles[3].Value != 0 {
+		t.Errorf("slowdown %v, queueing delay %v, max %v, want 1, 0, 0",
+			sum.Slowdown, sum.QueueDelay, sum.QueueDelayPercentiles[3].Value)
+	}
+	// The default percentiles are always reported
+	if sum.P50 != 10 || sum.P99 != 30 {
+		t.Errorf("p50 %v, p99 %v, want 10, 30", sum.P50, sum.P99)
+	}
+}
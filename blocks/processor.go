@@ -2,6 +2,7 @@ package blocks
 
 import (
 	"container/list"
+	"math"
 	//	"fmt"
 
 	"github.com/epfl-dcsl/schedsim/engine"
@@ -15,11 +16,68 @@ type Processor interface {
 	SetCtxCost(cost float64)
 }
 
+var ctxCostParam = Param{"ctxCost", FloatParam, 0.0, "overhead added to every scheduling decision"}
+
+func init() {
+	withCtxCost := func(p Processor, params Params) Processor {
+		p.SetCtxCost(params.Float("ctxCost"))
+		return p
+	}
+	RegisterProcessor("rtc", "run to completion", []Param{ctxCostParam},
+		func(p Params) Processor {
+			return withCtxCost(&RTCProcessor{}, p)
+		})
+	RegisterProcessor("ts", "time sharing",
+		[]Param{ctxCostParam, {"quantum", FloatParam, 1.0, "time slice"}},
+		func(p Params) Processor {
+			return withCtxCost(NewTSProcessor(p.Float("quantum")), p)
+		})
+	// Processor sharing has no scheduling decisions, so no ctxCost
+	RegisterProcessor("ps", "processor sharing",
+		[]Param{{"workers", IntParam, 1, "number of workers sharing the load"}},
+		func(p Params) Processor {
+			ps := NewPSProcessor()
+			ps.SetWorkerCount(p.Int("workers"))
+			return ps
+		})
+	RegisterProcessor("bounded", "forwards to its output queue while it has less than bufSize requests",
+		[]Param{ctxCostParam, {"bufSize", IntParam, 1, "size of the output buffer"}},
+		func(p Params) Processor {
+			return withCtxCost(NewBoundedProcessor(p.Int("bufSize")), p)
+		})
+	RegisterProcessor("bounded2", "second stage of the bounded queue topology", []Param{ctxCostParam},
+		func(p Params) Processor {
+			return withCtxCost(&BoundedProcessor2{}, p)
+		})
+}
+
 // generic processor: All processors should have it as an embedded field
 type genericProcessor struct {
 	engine.Actor
-	reqDrain RequestDrain
-	ctxCost  float64
+	reqDrain  RequestDrain
+	ctxCost   float64
+	inService int // requests being served
+
+	// Time accounting, see serve
+	busy, overhead float64
+	lastEnd        float64 // end of the last service period
+	lastWork       float64
+	warm           Usage // usage at the end of the warm-up
+	warmedUp       bool
+
+	// Completion notification, see NotifyCompletions
+	notify engine.QueueInterface
+	worker int
+}
+
+// Busy returns true if the processor is serving requests
+func (p *genericProcessor) Busy() bool {
+	return p.inService > 0
+}
+
+// InService returns the number of requests being served
+func (p *genericProcessor) InService() int {
+	return p.inService
 }
 
 func (p *genericProcessor) SetReqDrain(rd RequestDrain) {
@@ -30,6 +88,88 @@ func (p *genericProcessor) SetCtxCost(cost float64) {
 	p.ctxCost = cost
 }
 
+// startService records that the processor starts serving req
+func (p *genericProcessor) startService(req engine.ReqInterface) {
+	p.inService++
+	if r, ok := req.(lifecycle); ok {
+		r.startService(p.GetTime())
+	}
+}
+
+// stopService records that req goes back to a queue before completion
+func (p *genericProcessor) stopService(req engine.ReqInterface, preempted bool) {
+	p.inService--
+	if r, ok := req.(lifecycle); ok {
+		r.stopService(p.GetTime(), preempted)
+	}
+}
+
+// serve waits for the given useful work and scheduling overhead, and
+// accounts for them. The overhead comes first.
+func (p *genericProcessor) serve(work, overhead float64) {
+	p.account()
+	p.busy += work
+	p.overhead += overhead
+	p.lastEnd = p.GetTime() + overhead + work
+	p.lastWork = work
+	p.Wait(work + overhead)
+}
+
+// usageAt returns the time spent serving requests and in scheduling
+// overhead till t, at least the start of the last service period
+func (p *genericProcessor) usageAt(t float64) Usage {
+	u := Usage{Cores: 1, Busy: p.busy, Overhead: p.overhead}
+	// Remove the part of the last service period after t
+	if excess := p.lastEnd - t; excess > 0 {
+		work := math.Min(excess, p.lastWork)
+		u.Busy -= work
+		u.Overhead -= excess - work
+	}
+	return u
+}
+
+// account records the usage at the end of the warm-up once it is over. It
+// runs before every service period, so only the last one can straddle the
+// end of the warm-up.
+func (p *genericProcessor) account() {
+	if p.warmedUp {
+		return
+	}
+	if end, over := p.WarmupEnd(); over {
+		p.warm = p.usageAt(end)
+		p.warmedUp = true
+	}
+}
+
+// Usage returns the time spent serving requests and in scheduling overhead
+// since the end of the warm-up
+func (p *genericProcessor) Usage() Usage {
+	p.account()
+	if !p.warmedUp {
+		return Usage{Cores: 1}
+	}
+	u := p.usageAt(p.GetTime())
+	u.Busy -= p.warm.Busy
+	u.Overhead -= p.warm.Overhead
+	return u
+}
+
+// NotifyCompletions makes the processor write a notice with the worker
+// index to q for every request it completes, for a dispatcher
+func (p *genericProcessor) NotifyCompletions(q engine.QueueInterface, worker int) {
+	p.notify = q
+	p.worker = worker
+}
+
+// terminate hands a served request to the drain
+func (p *genericProcessor) terminate(req engine.ReqInterface) {
+	p.inService--
+	p.reqDrain.TerminateReq(req)
+	if p.notify != nil {
+		p.notify.Enqueue(&completionNotice{p.worker})
+	}
+}
+
 // RTCProcessor is a run to completion processor
 type RTCProcessor struct {
 	genericProcessor
@@ -40,11 +180,12 @@ type RTCProcessor struct {
 func (p *RTCProcessor) Run() {
 	for {
 		req := p.ReadInQueue()
-		p.Wait(req.GetServiceTime() + p.ctxCost)
+		p.startService(req)
+		p.serve(req.GetServiceTime(), p.ctxCost)
 		if monitorReq, ok := req.(*MonitorReq); ok {
 			monitorReq.finalLength = p.GetInQueueLen(0)
 		}
-		p.reqDrain.TerminateReq(req)
+		p.terminate(req)
 	}
 }
 
@@ -63,13 +204,15 @@ func NewTSProcessor(quantum float64) *TSProcessor {
 func (p *TSProcessor) Run() {
 	for {
 		req := p.ReadInQueue()
+		p.startService(req)
 
 		if req.GetServiceTime() <= p.quantum {
-			p.Wait(req.GetServiceTime() + p.ctxCost)
-			p.reqDrain.TerminateReq(req)
+			p.serve(req.GetServiceTime(), p.ctxCost)
+			p.terminate(req)
 		} else {
-			p.Wait(p.quantum + p.ctxCost)
+			p.serve(p.quantum, p.ctxCost)
 			req.SubServiceTime(p.quantum)
+			p.stopService(req, true)
 			p.WriteInQueue(req)
 		}
 	}
@@ -96,10 +239,10 @@ func (p *PSProcessor) SetWorkerCount(count int) {
 }
 
 func (p *PSProcessor) getMinService() *list.Element {
-	minS := p.reqList.Front().Value.(*Request).ServiceTime
+	minS := p.reqList.Front().Value.(engine.ReqInterface).GetServiceTime()
 	minI := p.reqList.Front()
 	for e := p.reqList.Front(); e != nil; e = e.Next() {
-		val := e.Value.(*Request).ServiceTime
+		val := e.Value.(engine.ReqInterface).GetServiceTime()
 		if val < minS {
 			minS = val
 			minI = e
@@ -115,9 +258,47 @@ func (p *PSProcessor) getFactor() float64 {
 	return float64(p.workerCount) / float64(p.count)
 }
 
+// busyAt returns the time spent serving requests till t, at least the time
+// of the last update
+func (p *PSProcessor) busyAt(t float64) float64 {
+	return p.busy + (t-p.prevTime)*p.busyFraction()
+}
+
+// account records the busy time at the end of the warm-up once it is over.
+// It runs before every update, so the end of the warm-up is never before
+// the last one.
+func (p *PSProcessor) account() {
+	if p.warmedUp {
+		return
+	}
+	if end, over := p.WarmupEnd(); over {
+		p.warm.Busy = p.busyAt(end)
+		p.warmedUp = true
+	}
+}
+
+// Usage returns the time spent serving requests since the end of the
+// warm-up. Busy time is the time the workers served requests, divided by the
+// number of workers.
+func (p *PSProcessor) Usage() Usage {
+	p.account()
+	u := Usage{Cores: p.workerCount}
+	if p.warmedUp {
+		u.Busy = p.busyAt(p.GetTime()) - p.warm.Busy
+	}
+	return u
+}
+
+// busyFraction returns the fraction of the workers serving requests
+func (p *PSProcessor) busyFraction() float64 {
+	return math.Min(float64(p.count), float64(p.workerCount)) / float64(p.workerCount)
+}
+
 func (p *PSProcessor) updateServiceTimes() {
-	currTime := engine.GetTime()
+	p.account()
+	currTime := p.GetTime()
 	diff := (currTime - p.prevTime) * p.getFactor()
+	p.busy += (currTime - p.prevTime) * p.busyFraction()
 	p.prevTime = currTime
 	for e := p.reqList.Front(); e != nil; e = e.Next() {
 		req := e.Value.(engine.ReqInterface)
@@ -135,11 +316,12 @@ func (p *PSProcessor) Run() {
 		p.updateServiceTimes()
 		if intr {
 			req := p.curr.Value.(engine.ReqInterface)
-			p.reqDrain.TerminateReq(req)
+			p.terminate(req)
 			p.reqList.Remove(p.curr)
 			p.count--
 		} else {
 			p.count++
+			p.startService(newReq)
 			p.reqList.PushBack(newReq)
 		}
 		if p.count > 0 {
@@ -165,6 +347,7 @@ func (p *BoundedProcessor) Run() {
 	var factor float64
 	for {
 		req := p.ReadInQueue()
+		p.startService(req)
 
 		if colorReq, ok := req.(*ColoredReq); ok {
 			if colorReq.color == 1 {
@@ -173,12 +356,13 @@ func (p *BoundedProcessor) Run() {
 				factor = 1
 			}
 		}
-		p.Wait(factor * req.GetServiceTime())
+		p.serve(factor*req.GetServiceTime(), p.ctxCost)
 		len := p.GetOutQueueLen(0)
 		if len < p.bufSize {
+			p.stopService(req, false)
 			p.WriteOutQueue(req)
 		} else {
-			p.reqDrain.TerminateReq(req)
+			p.terminate(req)
 		}
 	}
 }
@@ -192,6 +376,7 @@ func (p *BoundedProcessor2) Run() {
 	var factor float64
 	for {
 		req := p.ReadInQueue()
+		p.startService(req)
 
 		if colorReq, ok := req.(*ColoredReq); ok {
 			if colorReq.color == 0 {
@@ -200,7 +385,7 @@ func (p *BoundedProcessor2) Run() {
 				factor = 1
 			}
 		}
-		p.Wait(factor * req.GetServiceTime())
-		p.reqDrain.TerminateReq(req)
+		p.serve(factor*req.GetServiceTime(), p.ctxCost)
+		p.terminate(req)
 	}
 }
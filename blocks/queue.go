@@ -3,36 +3,79 @@ package blocks
 import (
 	//"container/heap"
 	"container/list"
+	"fmt"
+	"io"
+	"math"
+
 	//"sort"
-	//"fmt"
 	"github.com/epfl-dcsl/schedsim/engine"
 )
 
-var count = 0
-
-// Queue is a imple FIFO queue
+// Queue is a imple FIFO queue. Once registered in a simulation it keeps
+// time-weighted statistics of its length, after the warm-up.
 type Queue struct {
-	l  *list.List
-	id int
+	l   *list.List
+	sim *engine.Simulation
+
+	lastTime float64   // time of the last length change
+	timeAt   []float64 // time spent at every length
+	maxLen   int
+	enqueues int
+	dequeues int
 }
 
 // NewQueue returns a new *Queue
 func NewQueue() *Queue {
 	q := &Queue{}
 	q.l = list.New()
-	q.id = count
-	count++
 	return q
 }
 
+// SetSimulation binds the queue to the simulation it keeps statistics
+// for. It is called by the simulation when the queue is registered.
+func (q *Queue) SetSimulation(s *engine.Simulation) {
+	q.sim = s
+}
+
+// account adds the time since the last length change to the current length,
+// and returns true if the warm-up is over
+func (q *Queue) account() bool {
+	if q.sim == nil {
+		return false
+	}
+	end, over := q.sim.WarmupEnd()
+	now := q.sim.GetTime()
+	if over {
+		n := q.l.Len()
+		if n >= len(q.timeAt) {
+			q.timeAt = append(q.timeAt, make([]float64, n+1-len(q.timeAt))...)
+		}
+		q.timeAt[n] += now - math.Max(q.lastTime, end)
+		if n > q.maxLen {
+			q.maxLen = n
+		}
+	}
+	q.lastTime = now
+	return over
+}
+
 // Enqueue enqueues a new ReqInterface at the queue
 func (q *Queue) Enqueue(el engine.ReqInterface) {
-	//fmt.Printf("time: %v, queue: %v, len: %v\n", engine.GetTime(), q.id, q.Len())
+	over := q.account()
 	q.l.PushBack(el)
+	if over {
+		q.enqueues++
+		if q.l.Len() > q.maxLen {
+			q.maxLen = q.l.Len()
+		}
+	}
 }
 
 // Dequeue dequeues the last ReqInterface from the queue
 func (q *Queue) Dequeue() engine.ReqInterface {
+	if q.account() {
+		q.dequeues++
+	}
 	el := q.l.Front()
 	q.l.Remove(el)
 	return el.Value.(engine.ReqInterface)
@@ -42,3 +85,107 @@ func (q *Queue) Dequeue() engine.ReqInterface {
 func (q *Queue) Len() int {
 	return q.l.Len()
 }
+
+// QueueSummary holds the time-weighted length statistics of a queue.
+// LenDistribution holds the fraction of time spent at every length.
+type QueueSummary struct {
+	AvgLen          float64
+	MaxLen          int
+	Enqueues        int
+	Dequeues        int
+	LenDistribution []float64
+}
+
+// Summary returns the length statistics of the queue since the end of the
+// warm-up
+func (q *Queue) Summary() QueueSummary {
+	q.account()
+	res := QueueSummary{MaxLen: q.maxLen, Enqueues: q.enqueues, Dequeues: q.dequeues}
+	total := 0.0
+	for _, t := range q.timeAt {
+		total += t
+	}
+	if total == 0 {
+		return res
+	}
+	res.LenDistribution = make([]float64, len(q.timeAt))
+	for n, t := range q.timeAt {
+		res.LenDistribution[n] = t / total
+		res.AvgLen += float64(n) * t / total
+	}
+	return res
+}
+
+// QueueLengthStats reports the length statistics of every Queue of the
+// simulation, in registration order, and the average number of requests in
+// all of them. The average lengths can be checked against Little's law and
+// the queueing delay of the drains.
+type QueueLengthStats struct {
+	sim *engine.Simulation
+}
+
+// NewQueueLengthStats returns a new *QueueLengthStats
+func NewQueueLengthStats() *QueueLengthStats {
+	return &QueueLengthStats{}
+}
+
+// SetSimulation binds the collector to the simulation whose queues it
+// reports. It is called by the simulation when the collector is registered.
+func (s *QueueLengthStats) SetSimulation(sim *engine.Simulation) {
+	s.sim = sim
+}
+
+// summaries returns the summaries of all the Queues of the simulation and
+// the sum of their average lengths
+func (s *QueueLengthStats) summaries() ([]QueueSummary, float64) {
+	var res []QueueSummary
+	total := 0.0
+	for _, eq := range s.sim.Queues() {
+		if q, ok := eq.(*Queue); ok {
+			sum := q.Summary()
+			res = append(res, sum)
+			total += sum.AvgLen
+		}
+	}
+	return res, total
+}
+
+// PrintStats prints the queue length statistics at the end of the
+// simulation. This is called by the model
+func (s *QueueLengthStats) PrintStats(w io.Writer) {
+	sums, total := s.summaries()
+	fmt.Fprintf(w, "Stats collector: Queues\n")
+	fmt.Fprintf(w, "Queue\tAvgLen\tMaxLen\tEnqueues\tDequeues\n")
+	for i, sum := range sums {
+		fmt.Fprintf(w, "q%v\t%v\t%v\t%v\t%v\n", i, sum.AvgLen, sum.MaxLen, sum.Enqueues, sum.Dequeues)
+	}
+	fmt.Fprintf(w, "all\t%v\n", total)
+	fmt.Fprintf(w, "Length distribution (fraction of time at each length)\n")
+	for i, sum := range sums {
+		fmt.Fprintf(w, "q%v", i)
+		for n, f := range sum.LenDistribution {
+			fmt.Fprintf(w, "\t%v:%v", n, f)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// Record returns the queue length statistics as a Record, with metrics
+// prefixed by the queue
+func (s *QueueLengthStats) Record() Record {
+	sums, total := s.summaries()
+	res := Record{Collector: "Queues", Type: "queues"}
+	for i, sum := range sums {
+		prefix := fmt.Sprintf("q%v_", i)
+		res.Metrics = append(res.Metrics,
+			Metric{prefix + "avg_len", sum.AvgLen},
+			Metric{prefix + "max_len", float64(sum.MaxLen)},
+			Metric{prefix + "enqueues", float64(sum.Enqueues)},
+			Metric{prefix + "dequeues", float64(sum.Dequeues)})
+		for n, f := range sum.LenDistribution {
+			res.Metrics = append(res.Metrics, Metric{fmt.Sprintf("%vlen%v", prefix, n), f})
+		}
+	}
+	res.Metrics = append(res.Metrics, Metric{"all_avg_len", total})
+	return res
+}
@@ -0,0 +1,71 @@
+package blocks_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// scripted writes two requests to its queue at 0 and one at 10, then reads
+// one at 20 and one at 40
+type scripted struct {
+	engine.Actor
+}
+
+func (s *scripted) Run() {
+	s.WriteOutQueue(&blocks.Request{})
+	s.WriteOutQueue(&blocks.Request{})
+	s.Wait(10)
+	s.WriteOutQueue(&blocks.Request{})
+	s.Wait(10)
+	s.ReadInQueue()
+	s.Wait(20)
+	s.ReadInQueue()
+	s.Wait(60)
+}
+
+// Lengths are 2 till 10, 3 till 20, 2 till 40 and 1 till the end at 100,
+// and only the time after the warm-up at 5 counts
+func TestQueueSummary(t *testing.T) {
+	sim := engine.InitSim(1)
+	sim.SetWarmupTime(5)
+	q := blocks.NewQueue()
+	s := &scripted{}
+	s.AddInQueue(q)
+	s.AddOutQueue(q)
+	sim.RegisterActor(s)
+	sim.Run(100)
+
+	want := blocks.QueueSummary{
+		AvgLen:          (1*60 + 2*25 + 3*10) / 95.0,
+		MaxLen:          3,
+		Enqueues:        1,
+		Dequeues:        2,
+		LenDistribution: []float64{0, 60 / 95.0, 25 / 95.0, 10 / 95.0},
+	}
+	got := q.Summary()
+	checkClose(t, "average length", got.AvgLen, want.AvgLen, 1e-12)
+	got.AvgLen = want.AvgLen
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// The average number of waiting requests is the arrival rate times the
+// queueing delay
+func TestQueueLittlesLaw(t *testing.T) {
+	t.Parallel()
+	sim, stats := newTestSim(engine.Coroutines)
+	sim.SetWarmupTime(10000)
+	queues := blocks.NewQueueLengthStats()
+	sim.InitStats(queues)
+	connect(sim, stats, blocks.NewMMRandGenerator(0.015, 0.02), []blocks.Processor{rtc()})
+	sim.Run(1000000)
+
+	sum := stats.GetSummary()
+	r := queues.Record()
+	checkClose(t, "average length", metric(t, r, "q0_avg_len"), sum.Throughput*sum.QueueDelay, 0.02)
+	checkClose(t, "all queues", metric(t, r, "all_avg_len"), metric(t, r, "q0_avg_len"), 0)
+}
@@ -0,0 +1,71 @@
+package blocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+)
+
+// Metric is a named statistic of a Record
+type Metric struct {
+	Name  string
+	Value float64
+}
+
+// Metrics is an ordered list of metrics. It is encoded in JSON as an object
+// keeping the order, with null for values that are not numbers.
+type Metrics []Metric
+
+// MarshalJSON implements json.Marshaler
+func (m Metrics) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			buf.WriteString("null")
+			continue
+		}
+		val, err := json.Marshal(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// prefixed returns a copy of the metrics with prefixed names
+func prefixed(prefix string, m Metrics) Metrics {
+	res := make(Metrics, len(m))
+	for i, v := range m {
+		res[i] = Metric{prefix + v.Name, v.Value}
+	}
+	return res
+}
+
+// Record is the structured form of the statistics of a collector. Collectors
+// keeping per request data report it as Samples, one row per request with
+// the given Columns.
+type Record struct {
+	Collector string      `json:"collector"`
+	Type      string      `json:"type"`
+	Metrics   Metrics     `json:"metrics"`
+	Columns   []string    `json:"columns,omitempty"`
+	Samples   [][]float64 `json:"samples,omitempty"`
+}
+
+// Recorder is implemented by the Stats that can report their statistics as a
+// Record, in addition to printing them
+type Recorder interface {
+	Record() Record
+}
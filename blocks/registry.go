@@ -0,0 +1,467 @@
+package blocks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// ParamKind is the type of a component parameter
+type ParamKind int
+
+// Supported parameter types
+const (
+	FloatParam ParamKind = iota
+	IntParam
+	StringParam
+	StringListParam
+	PercentilesParam // list of percentiles, see ParsePercentile
+	FloatListParam
+	WorkloadsParam    // list of workloads, see ParseWorkload
+	VictimParam       // victim policy, see ParseVictimPolicy
+	DistributionParam // distribution, see ParseDistribution
+	DispatcherParam   // dispatch policy, see ParseDispatcher
+)
+
+func (k ParamKind) String() string {
+	switch k {
+	case FloatParam:
+		return "float"
+	case IntParam:
+		return "int"
+	case StringParam:
+		return "string"
+	case StringListParam:
+		return "[]string"
+	case PercentilesParam:
+		return "percentiles"
+	case FloatListParam:
+		return "[]float"
+	case WorkloadsParam:
+		return "workloads"
+	case VictimParam:
+		return "victim"
+	case DistributionParam:
+		return "distribution"
+	case DispatcherParam:
+		return "dispatcher"
+	}
+	return "unknown"
+}
+
+// Param describes a parameter of a registered component.
+// A nil Default makes the parameter required.
+type Param struct {
+	Name    string
+	Kind    ParamKind
+	Default interface{}
+	Doc     string
+}
+
+// Params holds component parameter values by name. Params returned by
+// Validate only hold values of the declared kind, so the getters don't fail.
+type Params map[string]interface{}
+
+// Float returns the value of a FloatParam
+func (p Params) Float(name string) float64 {
+	return p[name].(float64)
+}
+
+// Int returns the value of an IntParam
+func (p Params) Int(name string) int {
+	return p[name].(int)
+}
+
+// String returns the value of a StringParam
+func (p Params) String(name string) string {
+	return p[name].(string)
+}
+
+// Strings returns the value of a StringListParam
+func (p Params) Strings(name string) []string {
+	return p[name].([]string)
+}
+
+// Floats returns the value of a FloatListParam
+func (p Params) Floats(name string) []float64 {
+	return p[name].([]float64)
+}
+
+// Workloads returns the value of a WorkloadsParam
+func (p Params) Workloads(name string) []Workload {
+	return p[name].([]Workload)
+}
+
+// Victim returns the value of a VictimParam
+func (p Params) Victim(name string) VictimPolicy {
+	return p[name].(VictimPolicy)
+}
+
+// Distribution returns the value of a DistributionParam, nil if empty
+func (p Params) Distribution(name string) RandDist {
+	d, _ := p[name].(RandDist)
+	return d
+}
+
+// Dispatcher returns the value of a DispatcherParam
+func (p Params) Dispatcher(name string) Dispatcher {
+	return p[name].(Dispatcher)
+}
+
+// Quantiles returns the value of a PercentilesParam as quantiles between 0
+// and 1
+func (p Params) Quantiles(name string) []float64 {
+	return p[name].([]float64)
+}
+
+// ParseParams parses parameters given as a "name=value,name=value" string.
+// Values are kept as strings and converted by Validate.
+func ParseParams(s string) (Params, error) {
+	res := Params{}
+	if s == "" {
+		return res, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad parameter %q: expected name=value", kv)
+		}
+		res[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return res, nil
+}
+
+// ParseFloats parses a list of floats
+func ParseFloats(list []string) ([]float64, error) {
+	res := make([]float64, 0, len(list))
+	for _, s := range list {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+func convertParam(p Param, v interface{}) (interface{}, error) {
+	switch p.Kind {
+	case FloatParam:
+		switch val := v.(type) {
+		case float64:
+			return val, nil
+		case int:
+			return float64(val), nil
+		case string:
+			return strconv.ParseFloat(val, 64)
+		}
+	case IntParam:
+		switch val := v.(type) {
+		case int:
+			return val, nil
+		case float64:
+			if val == float64(int(val)) {
+				return int(val), nil
+			}
+		case string:
+			return strconv.Atoi(val)
+		}
+	case StringParam:
+		if val, ok := v.(string); ok {
+			return val, nil
+		}
+	case StringListParam:
+		switch val := v.(type) {
+		case []string:
+			return val, nil
+		case string:
+			return strings.Split(val, ":"), nil
+		case []interface{}:
+			res := make([]string, len(val))
+			for i, s := range val {
+				str, ok := s.(string)
+				if !ok {
+					return nil, fmt.Errorf("expected %v", p.Kind)
+				}
+				res[i] = str
+			}
+			return res, nil
+		}
+	case PercentilesParam:
+		if val, ok := v.([]float64); ok {
+			return val, nil
+		}
+		list, err := convertParam(Param{p.Name, StringListParam, nil, ""}, v)
+		if err != nil {
+			return nil, fmt.Errorf("expected %v", p.Kind)
+		}
+		return ParsePercentiles(list.([]string))
+	case FloatListParam:
+		if val, ok := v.([]float64); ok {
+			return val, nil
+		}
+		if val, ok := v.([]interface{}); ok {
+			res := make([]float64, len(val))
+			for i, f := range val {
+				conv, err := convertParam(Param{p.Name, FloatParam, nil, ""}, f)
+				if err != nil {
+					return nil, fmt.Errorf("expected %v", p.Kind)
+				}
+				res[i] = conv.(float64)
+			}
+			return res, nil
+		}
+		if val, ok := v.(string); ok {
+			return ParseFloats(strings.Split(val, ":"))
+		}
+	case WorkloadsParam:
+		if val, ok := v.([]Workload); ok {
+			return val, nil
+		}
+		list, err := convertParam(Param{p.Name, StringListParam, nil, ""}, v)
+		if err != nil {
+			return nil, fmt.Errorf("expected %v", p.Kind)
+		}
+		var res []Workload
+		for _, s := range list.([]string) {
+			w, err := ParseWorkload(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, w)
+		}
+		if len(res) == 0 {
+			return nil, fmt.Errorf("no workloads")
+		}
+		return res, nil
+	case VictimParam:
+		switch val := v.(type) {
+		case VictimPolicy:
+			return val, nil
+		case string:
+			return ParseVictimPolicy(val)
+		}
+	case DistributionParam:
+		switch val := v.(type) {
+		case RandDist:
+			return val, nil
+		case string:
+			if val == "" {
+				return nil, nil
+			}
+			return ParseDistribution(val)
+		}
+	case DispatcherParam:
+		switch val := v.(type) {
+		case Dispatcher:
+			return val, nil
+		case string:
+			return ParseDispatcher(val)
+		}
+	}
+	return nil, fmt.Errorf("expected %v", p.Kind)
+}
+
+// ComponentInfo describes a registered component
+type ComponentInfo struct {
+	Kind   string // generator, processor, distribution, dispatcher, drain or creator
+	Name   string
+	Doc    string
+	Params []Param
+}
+
+// HasParam returns true if the component has a parameter with that name
+func (c ComponentInfo) HasParam(name string) bool {
+	for _, p := range c.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the given parameters against the component schema and
+// returns them converted to their declared kinds, with defaults filled in
+func (c ComponentInfo) Validate(given Params) (Params, error) {
+	res := Params{}
+	for name := range given {
+		if !c.HasParam(name) {
+			return nil, fmt.Errorf("%v %v: unknown parameter %v", c.Kind, c.Name, name)
+		}
+	}
+	for _, p := range c.Params {
+		v, ok := given[p.Name]
+		if !ok {
+			if p.Default == nil {
+				return nil, fmt.Errorf("%v %v: missing parameter %v", c.Kind, c.Name, p.Name)
+			}
+			v = p.Default
+		}
+		conv, err := convertParam(p, v)
+		if err != nil {
+			return nil, fmt.Errorf("%v %v: parameter %v: %v", c.Kind, c.Name, p.Name, err)
+		}
+		res[p.Name] = conv
+	}
+	return res, nil
+}
+
+// Component kinds
+const (
+	generatorKind    = "generator"
+	processorKind    = "processor"
+	distributionKind = "distribution"
+	dispatcherKind   = "dispatcher"
+	drainKind        = "drain"
+	creatorKind      = "creator"
+)
+
+type component struct {
+	ComponentInfo
+	ctor interface{}
+}
+
+var registry = map[string]map[string]component{}
+
+func register(kind, name, doc string, params []Param, ctor interface{}) {
+	if _, ok := registry[kind]; !ok {
+		registry[kind] = map[string]component{}
+	}
+	if _, ok := registry[kind][name]; ok {
+		panic(fmt.Sprintf("%v %v registered twice", kind, name))
+	}
+	registry[kind][name] = component{ComponentInfo{kind, name, doc, params}, ctor}
+}
+
+func lookup(kind, name string, given Params) (component, Params, error) {
+	c, ok := registry[kind][name]
+	if !ok {
+		return component{}, nil, fmt.Errorf("unknown %v: %v", kind, name)
+	}
+	p, err := c.Validate(given)
+	return c, p, err
+}
+
+// RegisterGenerator registers a generator constructor under name.
+// The constructor can reject its parameters.
+func RegisterGenerator(name, doc string, params []Param, ctor func(Params) (Generator, error)) {
+	register(generatorKind, name, doc, params, ctor)
+}
+
+// RegisterProcessor registers a processor constructor under name
+func RegisterProcessor(name, doc string, params []Param, ctor func(Params) Processor) {
+	register(processorKind, name, doc, params, ctor)
+}
+
+// RegisterDistribution registers a distribution constructor under name
+func RegisterDistribution(name, doc string, params []Param, ctor func(Params) RandDist) {
+	register(distributionKind, name, doc, params, ctor)
+}
+
+// RegisterDispatcher registers a dispatch policy constructor under name.
+// The constructor can reject its parameters.
+func RegisterDispatcher(name, doc string, params []Param, ctor func(Params) (Dispatcher, error)) {
+	register(dispatcherKind, name, doc, params, ctor)
+}
+
+// RegisterDrain registers a request drain constructor under name. Drains
+// are also the statistics collectors of the simulation. The constructor can
+// reject its parameters.
+func RegisterDrain(name, doc string, params []Param, ctor func(Params) (StatsDrain, error)) {
+	register(drainKind, name, doc, params, ctor)
+}
+
+// RegisterCreator registers a request creator constructor under name
+func RegisterCreator(name, doc string, params []Param, ctor func(*engine.Simulation, Params) ReqCreator) {
+	register(creatorKind, name, doc, params, ctor)
+}
+
+// NewGenerator creates the generator registered under name
+func NewGenerator(name string, params Params) (Generator, error) {
+	c, p, err := lookup(generatorKind, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.ctor.(func(Params) (Generator, error))(p)
+}
+
+// NewProcessor creates the processor registered under name
+func NewProcessor(name string, params Params) (Processor, error) {
+	c, p, err := lookup(processorKind, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.ctor.(func(Params) Processor)(p), nil
+}
+
+// NewDistribution creates the distribution registered under name
+func NewDistribution(name string, params Params) (RandDist, error) {
+	c, p, err := lookup(distributionKind, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.ctor.(func(Params) RandDist)(p), nil
+}
+
+// NewDispatcher creates the dispatch policy registered under name
+func NewDispatcher(name string, params Params) (Dispatcher, error) {
+	c, p, err := lookup(dispatcherKind, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.ctor.(func(Params) (Dispatcher, error))(p)
+}
+
+// NewDrain creates the request drain registered under name
+func NewDrain(name string, params Params) (StatsDrain, error) {
+	c, p, err := lookup(drainKind, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.ctor.(func(Params) (StatsDrain, error))(p)
+}
+
+// NewCreator creates the request creator registered under name
+func NewCreator(sim *engine.Simulation, name string, params Params) (ReqCreator, error) {
+	c, p, err := lookup(creatorKind, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.ctor.(func(*engine.Simulation, Params) ReqCreator)(sim, p), nil
+}
+
+// LookupGenerator returns the description of the generator registered
+// under name
+func LookupGenerator(name string) (ComponentInfo, bool) {
+	c, ok := registry[generatorKind][name]
+	return c.ComponentInfo, ok
+}
+
+// LookupProcessor returns the description of the processor registered
+// under name
+func LookupProcessor(name string) (ComponentInfo, bool) {
+	c, ok := registry[processorKind][name]
+	return c.ComponentInfo, ok
+}
+
+// Components returns all the registered components sorted by kind and name
+func Components() []ComponentInfo {
+	var res []ComponentInfo
+	for _, kind := range []string{generatorKind, processorKind, distributionKind, dispatcherKind, drainKind, creatorKind} {
+		var names []string
+		for name := range registry[kind] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res = append(res, registry[kind][name].ComponentInfo)
+		}
+	}
+	return res
+}
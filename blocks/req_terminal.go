@@ -2,35 +2,176 @@ package blocks
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"sort"
 
 	"github.com/epfl-dcsl/schedsim/engine"
 )
 
-const (
-	bUCKETCOUNT = 100000
-	gRANULARITY = 0.01
-)
-
 // RequestDrain describes the behaviour of a the element that receives a request
 // after processor serving and is in charge of keeping the statistics
 type RequestDrain interface {
 	TerminateReq(r engine.ReqInterface)
 	SetName(name string)
+	Count() int // number of terminated requests
+}
+
+// Summarizer is implemented by the drains that summarise their statistics
+// in a Summary
+type Summarizer interface {
+	GetSummary() Summary
+}
+
+// StatsDrain is a RequestDrain that also collects the statistics reported
+// at the end of the simulation
+type StatsDrain interface {
+	RequestDrain
+	engine.Stats
+}
+
+func init() {
+	RegisterDrain("all", "keeps every latency sample",
+		[]Param{percentilesParam, {"classes", FloatListParam, []float64{},
+			"service time class boundaries, like 10:100"}},
+		func(p Params) (StatsDrain, error) {
+			k := &AllKeeper{}
+			k.SetPercentiles(p.Quantiles("percentiles"))
+			k.SetServiceClasses(p.Floats("classes"))
+			return k, nil
+		})
+	RegisterDrain("book", "keeps latencies in logarithmic histograms, with bounded memory",
+		[]Param{percentilesParam, {"relErr", FloatParam, 0.001, "relative error of the percentiles"}},
+		func(p Params) (StatsDrain, error) {
+			b, err := NewBookKeeper(p.Float("relErr"))
+			if err != nil {
+				return nil, err
+			}
+			b.SetPercentiles(p.Quantiles("percentiles"))
+			return b, nil
+		})
+	RegisterDrain("monitor", "keeps latencies and queue lengths of monitor requests", nil,
+		func(p Params) (StatsDrain, error) {
+			return &MonitorKeeper{}, nil
+		})
+}
+
+// generic keeper: All request drains should have it as an embedded field
+type genericKeeper struct {
+	name string
+	sim  *engine.Simulation
+}
+
+// SetName gives a name to the particular keeper
+func (k *genericKeeper) SetName(name string) {
+	k.name = name
+}
+
+// SetSimulation binds the keeper to the simulation it collects statistics
+// for. It is called by the simulation when the keeper is registered.
+func (k *genericKeeper) SetSimulation(s *engine.Simulation) {
+	k.sim = s
+}
+
+// measure notifies the simulation of a completed request and returns true if
+// the request should be recorded, i.e. it was born after the warm-up
+func (k *genericKeeper) measure(req engine.ReqInterface) bool {
+	k.sim.NotifyCompletion()
+	end, over := k.sim.WarmupEnd()
+	if !over {
+		return false
+	}
+	if r, ok := req.(TrackedReq); ok && r.ArrivalTime() < end {
+		return false
+	}
+	return true
 }
 
 // AllKeeper implements the RequestDrain interface and caclulates statistics
 // on all the given requests, without sampling
 type AllKeeper struct {
+	genericKeeper
 	items       []float64
-	name        string
 	stolenCount int
+	percentiles []float64
+	classes     []float64 // service time class boundaries
+
+	// Per request service time, queueing delay (time spent queued, see
+	// TrackedReq) and slowdown (latency divided by service time). Service
+	// times and queueing delays are NaN for requests not tracking them.
+	serviceTimes []float64
+	queueDelays  []float64
+	slowdowns    []float64
+	preemptions  int // of all the requests tracking them
+
+	// Statistics per request class, see MixGenerator
+	byClass map[string]*AllKeeper
+}
+
+// ServiceClassSetter is implemented by the collectors reporting statistics
+// by service time class
+type ServiceClassSetter interface {
+	SetServiceClasses(bounds []float64)
+}
+
+// SetServiceClasses splits the requests in classes by their original service
+// time. Bounds b1 < b2 < ... make the classes [0, b1), [b1, b2), ...
+func (k *AllKeeper) SetServiceClasses(bounds []float64) {
+	k.classes = append([]float64(nil), bounds...)
+	sort.Float64s(k.classes)
+}
+
+// SetPercentiles selects the reported percentiles, given as quantiles
+// between 0 and 1
+func (k *AllKeeper) SetPercentiles(quantiles []float64) {
+	k.percentiles = quantiles
+}
+
+func (k *AllKeeper) quantiles() []float64 {
+	if k.percentiles == nil {
+		return defaultPercentiles
+	}
+	return k.percentiles
 }
 
 // TerminateReq is the function called by the processor after finishing
 // request processing
 func (k *AllKeeper) TerminateReq(req engine.ReqInterface) {
+	if !k.measure(req) {
+		return
+	}
+	k.record(req)
+	if r, ok := req.(classedReq); ok && r.Class() != "" {
+		k.classKeeper(r.Class()).record(req)
+	}
+}
+
+// classKeeper returns the keeper of the requests of a class
+func (k *AllKeeper) classKeeper(class string) *AllKeeper {
+	if c, ok := k.byClass[class]; ok {
+		return c
+	}
+	if k.byClass == nil {
+		k.byClass = make(map[string]*AllKeeper)
+	}
+	c := &AllKeeper{genericKeeper: k.genericKeeper, percentiles: k.percentiles,
+		classes: k.classes}
+	c.name = k.name + "/" + class
+	k.byClass[class] = c
+	return c
+}
+
+// requestClasses returns the request classes of a per class map, sorted
+func requestClasses[K any](byClass map[string]K) []string {
+	var res []string
+	for class := range byClass {
+		res = append(res, class)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (k *AllKeeper) record(req engine.ReqInterface) {
 	d := req.GetDelay()
 	k.items = append(k.items, d)
 	if stealable, ok := req.(*StealableReq); ok {
@@ -38,19 +179,40 @@ func (k *AllKeeper) TerminateReq(req engine.ReqInterface) {
 			k.stolenCount++
 		}
 	}
+	st, wait := math.NaN(), math.NaN()
+	if r, ok := req.(TrackedReq); ok {
+		st = r.OriginalServiceTime()
+		wait = r.WaitTime()
+		k.preemptions += r.Preemptions()
+	}
+	k.serviceTimes = append(k.serviceTimes, st)
+	k.queueDelays = append(k.queueDelays, wait)
+	if st > 0 {
+		k.slowdowns = append(k.slowdowns, d/st)
+	}
 }
 
-// SetName gives a name to the particular AllKeeper
-func (k *AllKeeper) SetName(name string) {
-	k.name = name
-}
-
-func (k *AllKeeper) avg() float64 {
+func mean(samples []float64) float64 {
 	tmp := 0.0
-	for _, v := range k.items {
+	for _, v := range samples {
 		tmp += v
 	}
-	return tmp / float64(len(k.items))
+	return tmp / float64(len(samples))
+}
+
+// withoutNaN returns the samples that are numbers
+func withoutNaN(samples []float64) []float64 {
+	var res []float64
+	for _, v := range samples {
+		if !math.IsNaN(v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func (k *AllKeeper) avg() float64 {
+	return mean(k.items)
 }
 
 func (k *AllKeeper) std() float64 {
@@ -58,201 +220,483 @@ func (k *AllKeeper) std() float64 {
 	for _, v := range k.items {
 		tmp += v * v
 	}
-	return math.Sqrt((tmp/float64(len(k.items)) - k.avg()))
+	avg := k.avg()
+	return math.Sqrt(tmp/float64(len(k.items)) - avg*avg)
+}
+
+// Count returns the number of terminated requests
+func (k *AllKeeper) Count() int {
+	return len(k.items)
+}
+
+// Summary holds the statistics collected by an AllKeeper. P50 to P99 are
+// always reported, Percentiles holds the selected percentiles.
+type Summary struct {
+	Name       string  `json:"name"`
+	Count      int     `json:"count"`
+	Stolen     int     `json:"stolen"`
+	Avg        float64 `json:"avg"`
+	StdDev     float64 `json:"stddev"`
+	P50        float64 `json:"p50"`
+	P90        float64 `json:"p90"`
+	P95        float64 `json:"p95"`
+	P99        float64 `json:"p99"`
+	Throughput float64 `json:"reqs_per_time_unit"`
+
+	Percentiles           Metrics `json:"percentiles"`
+	ServiceTime           float64 `json:"service_time"`
+	Preemptions           float64 `json:"preemptions"` // per request
+	QueueDelay            float64 `json:"queue_delay"`
+	QueueDelayPercentiles Metrics `json:"queue_delay_percentiles"`
+	Slowdown              float64 `json:"slowdown"`
+	SlowdownPercentiles   Metrics `json:"slowdown_percentiles"`
+
+	Classes []ClassSummary `json:"classes,omitempty"`
+
+	// Statistics of every request class, named <collector>/<class>
+	PerClass []Summary `json:"per_class,omitempty"`
+}
+
+// ClassSummary holds the latency and slowdown of the requests of a service
+// time class. Classes are named st<lower>-<upper>, the last one
+// st<lower>+.
+type ClassSummary struct {
+	Name                string  `json:"name"`
+	Count               int     `json:"count"`
+	Avg                 float64 `json:"avg"`
+	Percentiles         Metrics `json:"percentiles"`
+	Slowdown            float64 `json:"slowdown"`
+	SlowdownPercentiles Metrics `json:"slowdown_percentiles"`
+}
+
+func className(bounds []float64, i int) string {
+	lower := 0.0
+	if i > 0 {
+		lower = bounds[i-1]
+	}
+	if i == len(bounds) {
+		return fmt.Sprintf("st%v+", lower)
+	}
+	return fmt.Sprintf("st%v-%v", lower, bounds[i])
 }
 
-func (k *AllKeeper) getPercentiles() map[float64]float64 {
-	res := make(map[float64]float64)
-	sort.Float64s(k.items)
-	for _, v := range []float64{0.5, 0.9, 0.95, 0.99} {
-		idx := int(float64(len(k.items)) * v)
-		res[v] = k.items[idx]
+// classSummaries returns the statistics of every service time class
+func (k *AllKeeper) classSummaries() []ClassSummary {
+	qs := k.quantiles()
+	latencies := make([][]float64, len(k.classes)+1)
+	slowdowns := make([][]float64, len(k.classes)+1)
+	for i, d := range k.items {
+		st := k.serviceTimes[i]
+		if math.IsNaN(st) {
+			continue
+		}
+		c := sort.Search(len(k.classes), func(j int) bool { return k.classes[j] > st })
+		latencies[c] = append(latencies[c], d)
+		if st > 0 {
+			slowdowns[c] = append(slowdowns[c], d/st)
+		}
+	}
+	res := make([]ClassSummary, len(latencies))
+	for c := range res {
+		res[c] = ClassSummary{
+			Name:                className(k.classes, c),
+			Count:               len(latencies[c]),
+			Percentiles:         percentileMetrics("", qs, percentilesOf(latencies[c], qs)),
+			SlowdownPercentiles: percentileMetrics("", qs, percentilesOf(slowdowns[c], qs)),
+		}
+		if len(latencies[c]) > 0 {
+			res[c].Avg = mean(latencies[c])
+		}
+		if len(slowdowns[c]) > 0 {
+			res[c].Slowdown = mean(slowdowns[c])
+		}
+	}
+	return res
+}
+
+// GetSummary returns the statistics collected so far. Latency statistics are
+// zero if no request has completed, and throughput is zero until the end of
+// the warm-up.
+func (k *AllKeeper) GetSummary() Summary {
+	res := Summary{
+		Name:   k.name,
+		Count:  len(k.items),
+		Stolen: k.stolenCount,
+	}
+	if t := k.sim.MeasurementTime(); t > 0 {
+		res.Throughput = float64(len(k.items)) / t
+	}
+	qs := k.quantiles()
+	res.Percentiles = percentileMetrics("", qs, percentilesOf(k.items, qs))
+	queueDelays := withoutNaN(k.queueDelays)
+	res.QueueDelayPercentiles = percentileMetrics("", qs, percentilesOf(queueDelays, qs))
+	res.SlowdownPercentiles = percentileMetrics("", qs, percentilesOf(k.slowdowns, qs))
+	if len(k.items) > 0 {
+		res.Avg = k.avg()
+		res.StdDev = k.std()
+		percentiles := percentilesOf(k.items, defaultPercentiles)
+		res.P50 = percentiles[0]
+		res.P90 = percentiles[1]
+		res.P95 = percentiles[2]
+		res.P99 = percentiles[3]
+	}
+	if len(queueDelays) > 0 {
+		res.ServiceTime = mean(withoutNaN(k.serviceTimes))
+		res.Preemptions = float64(k.preemptions) / float64(len(queueDelays))
+		res.QueueDelay = mean(queueDelays)
+	}
+	if len(k.slowdowns) > 0 {
+		res.Slowdown = mean(k.slowdowns)
+	}
+	if len(k.classes) > 0 {
+		res.Classes = k.classSummaries()
+	}
+	for _, class := range requestClasses(k.byClass) {
+		res.PerClass = append(res.PerClass, k.byClass[class].GetSummary())
 	}
 	return res
 }
 
 // PrintStats prints the collected statistics at the end of the similation.
 // This is called by the model
-func (k *AllKeeper) PrintStats() {
-	fmt.Printf("Stats collector: %v\n", k.name)
-	fmt.Printf("Count\tStolen\tAVG\tSTDDev\t50th\t90th\t95th\t99th\tReqs/time_unit\n")
-	fmt.Printf("%v\t%v\t%v\t%v\t", len(k.items), k.stolenCount, k.avg(), k.std())
+func (k *AllKeeper) PrintStats(w io.Writer) {
+	sum := k.GetSummary()
+	qs := k.quantiles()
+	fmt.Fprintf(w, "Stats collector: %v\n", sum.Name)
+	printSummary(w, sum, qs)
+	for _, c := range sum.PerClass {
+		fmt.Fprintf(w, "Request class: %v\n", c.Name)
+		printSummary(w, c, qs)
+	}
+}
 
-	vals := []float64{0.5, 0.9, 0.95, 0.99}
-	if len(k.items) > 0 {
-		percentiles := k.getPercentiles()
-		for _, v := range vals {
-			fmt.Printf("%v\t", percentiles[v])
+func printSummary(w io.Writer, sum Summary, qs []float64) {
+	fmt.Fprintf(w, "Count\tStolen\tAVG\tSTDDev\t")
+	for _, q := range qs {
+		fmt.Fprintf(w, "%v\t", percentileHeader(q))
+	}
+	fmt.Fprintf(w, "Reqs/time_unit\n")
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t", sum.Count, sum.Stolen, sum.Avg, sum.StdDev)
+	if sum.Count > 0 {
+		for _, m := range sum.Percentiles {
+			fmt.Fprintf(w, "%v\t", m.Value)
 		}
 	}
-	fmt.Printf("%v\n", float64(len(k.items))/engine.GetTime())
+	fmt.Fprintf(w, "%v\n", sum.Throughput)
+	if sum.Count == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Service time: %v\tPreemptions: %v\n", sum.ServiceTime, sum.Preemptions)
+	fmt.Fprintf(w, "Queueing delay: %v\t", sum.QueueDelay)
+	printPercentiles(w, qs, sum.QueueDelayPercentiles)
+	fmt.Fprintf(w, "Slowdown: %v\t", sum.Slowdown)
+	printPercentiles(w, qs, sum.SlowdownPercentiles)
+	for _, c := range sum.Classes {
+		fmt.Fprintf(w, "Class %v: count: %v\n", c.Name, c.Count)
+		fmt.Fprintf(w, "Latency: %v\t", c.Avg)
+		printPercentiles(w, qs, c.Percentiles)
+		fmt.Fprintf(w, "Slowdown: %v\t", c.Slowdown)
+		printPercentiles(w, qs, c.SlowdownPercentiles)
+	}
+}
+
+func printPercentiles(w io.Writer, quantiles []float64, m Metrics) {
+	for i, q := range quantiles {
+		fmt.Fprintf(w, "%v: %v\t", percentileHeader(q), m[i].Value)
+	}
+	fmt.Fprintln(w)
+}
+
+// Record returns the statistics collected so far as a Record. The metrics of
+// every request class follow, prefixed with <class>/.
+func (k *AllKeeper) Record() Record {
+	sum := k.GetSummary()
+	metrics := summaryMetrics(sum)
+	for i, class := range requestClasses(k.byClass) {
+		metrics = append(metrics, prefixed(class+"/", summaryMetrics(sum.PerClass[i]))...)
+	}
+	return Record{
+		Collector: sum.Name,
+		Type:      "all",
+		Metrics:   metrics,
+	}
+}
+
+func summaryMetrics(sum Summary) Metrics {
+	metrics := Metrics{
+		{"count", float64(sum.Count)},
+		{"stolen", float64(sum.Stolen)},
+		{"avg", sum.Avg},
+		{"stddev", sum.StdDev},
+	}
+	metrics = append(metrics, sum.Percentiles...)
+	metrics = append(metrics, Metric{"reqs_per_time_unit", sum.Throughput},
+		Metric{"service_time", sum.ServiceTime},
+		Metric{"preemptions", sum.Preemptions},
+		Metric{"queue_delay", sum.QueueDelay})
+	metrics = append(metrics, prefixed("queue_delay_", sum.QueueDelayPercentiles)...)
+	metrics = append(metrics, Metric{"slowdown", sum.Slowdown})
+	metrics = append(metrics, prefixed("slowdown_", sum.SlowdownPercentiles)...)
+	for _, c := range sum.Classes {
+		prefix := c.Name + "_"
+		metrics = append(metrics, Metric{prefix + "count", float64(c.Count)},
+			Metric{prefix + "avg", c.Avg})
+		metrics = append(metrics, prefixed(prefix, c.Percentiles)...)
+		metrics = append(metrics, Metric{prefix + "slowdown", c.Slowdown})
+		metrics = append(metrics, prefixed(prefix+"slowdown_", c.SlowdownPercentiles)...)
+	}
+	return metrics
 }
 
-// MonitorKeeper keeps statistics about queue lengths
+// MonitorKeeper keeps statistics about queue lengths. Requests that do not
+// track queue lengths, the ones that are not MonitorReqs, have lengths of -1.
 type MonitorKeeper struct {
+	genericKeeper
 	delays   []float64
 	initLen  []int
 	finalLen []int
-	name     string
 }
 
 // TerminateReq is the function called by the processor after finishing
 // request processing
 func (k *MonitorKeeper) TerminateReq(req engine.ReqInterface) {
+	if !k.measure(req) {
+		return
+	}
 	k.delays = append(k.delays, req.GetDelay())
 
+	initLen, finalLen := -1, -1
 	if monitorReq, ok := req.(*MonitorReq); ok {
-		k.initLen = append(k.initLen, monitorReq.getInitLen())
-		k.finalLen = append(k.finalLen, monitorReq.getFinalLen())
+		initLen, finalLen = monitorReq.getInitLen(), monitorReq.getFinalLen()
 	}
+	k.initLen = append(k.initLen, initLen)
+	k.finalLen = append(k.finalLen, finalLen)
+}
+
+// Count returns the number of terminated requests
+func (k *MonitorKeeper) Count() int {
+	return len(k.delays)
 }
 
 // PrintStats prints the collected statistics at the end of the similation.
 // This is called by the model
-func (k *MonitorKeeper) PrintStats() {
-	fmt.Println("#Latency\tEntrace Queue\tExit Queue")
+func (k *MonitorKeeper) PrintStats(w io.Writer) {
+	fmt.Fprintln(w, "#Latency\tEntrace Queue\tExit Queue")
 	for idx, d := range k.delays {
-		fmt.Printf("%v\t%v\t%v\n", d, k.initLen[idx], k.finalLen[idx])
+		fmt.Fprintf(w, "%v\t%v\t%v\n", d, k.initLen[idx], k.finalLen[idx])
 	}
 }
 
-// SetName gives a name to the particular AllKeeper
-func (k *MonitorKeeper) SetName(name string) {
-	k.name = name
+// Record returns the statistics collected so far as a Record, with the
+// latency and queue lengths of every monitor request as samples
+func (k *MonitorKeeper) Record() Record {
+	avg := 0.0
+	for _, d := range k.delays {
+		avg += d
+	}
+	res := Record{
+		Collector: k.name,
+		Type:      "monitor",
+		Metrics: Metrics{
+			{"count", float64(len(k.delays))},
+			{"avg", avg / float64(len(k.delays))},
+		},
+		Columns: []string{"latency", "entrance_queue", "exit_queue"},
+	}
+	for idx := range k.initLen {
+		res.Samples = append(res.Samples, []float64{k.delays[idx],
+			float64(k.initLen[idx]), float64(k.finalLen[idx])})
+	}
+	return res
 }
 
-type histogram struct {
-	granularity float64
-	buckets     []int
-	count       int64
-	minBucket   int
-	maxBucket   int
-	sum         float64
-	sumSquare   float64
-}
+// BookKeeper keeps latencies, queueing delays and slowdowns in logarithmic
+// histograms, so its memory does not grow with the number of requests.
+// Percentiles are within the relative error of the histograms. Like the
+// AllKeeper it reports every request class, but it does not keep the service
+// times needed by service time classes.
+type BookKeeper struct {
+	genericKeeper
+	relErr      float64
+	latency     *Histogram
+	queueDelay  *Histogram
+	slowdown    *Histogram
+	serviceSum  float64
+	preemptions int
+	stolenCount int
+	percentiles []float64
 
-func newHistogram() *histogram {
-	return &histogram{
-		granularity: gRANULARITY,
-		buckets:     make([]int, bUCKETCOUNT),
-		minBucket:   bUCKETCOUNT - 1,
-		maxBucket:   0,
-	}
+	// Statistics per request class, see MixGenerator
+	byClass map[string]*BookKeeper
 }
 
-func (hdr *histogram) addSample(s float64) {
-	index := int(s / hdr.granularity)
-	if index >= bUCKETCOUNT {
-		index = bUCKETCOUNT - 1
-	}
-	if index < 0 || index >= bUCKETCOUNT {
-		panic(fmt.Sprintf("Wrong index: %v\n", index))
-	}
-	hdr.buckets[index]++
-	if index > hdr.maxBucket {
-		hdr.maxBucket = index
-	}
-	if index < hdr.minBucket {
-		hdr.minBucket = index
+// NewBookKeeper returns a new *BookKeeper with histograms of the given
+// relative error, strictly between 0 and 1
+func NewBookKeeper(relErr float64) (*BookKeeper, error) {
+	var hists [3]*Histogram
+	for i := range hists {
+		h, err := NewHistogram(relErr)
+		if err != nil {
+			return nil, err
+		}
+		hists[i] = h
 	}
-	hdr.count++
-	hdr.sum += s
-	hdr.sumSquare += s * s
+	return &BookKeeper{relErr: relErr, latency: hists[0], queueDelay: hists[1],
+		slowdown: hists[2]}, nil
 }
 
-func (hdr *histogram) avg() float64 {
-	return hdr.sum / float64(hdr.count)
+// SetPercentiles selects the reported percentiles, given as quantiles
+// between 0 and 1
+func (b *BookKeeper) SetPercentiles(quantiles []float64) {
+	b.percentiles = quantiles
 }
 
-func (hdr *histogram) stddev() float64 {
-	squareAvg := hdr.sumSquare / float64(hdr.count)
-	mean := hdr.avg()
-
-	return math.Sqrt(squareAvg - mean*mean)
+func (b *BookKeeper) quantiles() []float64 {
+	if b.percentiles == nil {
+		return defaultPercentiles
+	}
+	return b.percentiles
 }
 
-//FIXME: I assume that in every bucket there will be max one percentile
-func (hdr *histogram) getPercentiles() map[float64]float64 {
-	accum := make([]int, bUCKETCOUNT)
-	res := map[float64]float64{}
-	percentiles := []float64{0.5, 0.9, 0.95, 0.99}
-	percentileI := 0
-
-	accum[hdr.minBucket] = hdr.buckets[hdr.minBucket]
-
-	// what if percentiles in the first bucket
-	for float64(accum[hdr.minBucket]) > percentiles[percentileI]*float64(hdr.count) {
-		// linear interpolation
-		res[percentiles[percentileI]] = hdr.granularity / float64(hdr.buckets[hdr.minBucket]) * (percentiles[percentileI] * float64(hdr.count))
-		percentileI++
+// TerminateReq is the function called by the processor after finishing
+// request processing
+func (b *BookKeeper) TerminateReq(req engine.ReqInterface) {
+	if !b.measure(req) {
+		return
 	}
-	if percentileI >= len(percentiles) {
-		return res
+	b.record(req)
+	if r, ok := req.(classedReq); ok && r.Class() != "" {
+		b.classKeeper(r.Class()).record(req)
 	}
+}
 
-	for i := hdr.minBucket + 1; i <= hdr.maxBucket; i++ {
-		accum[i] = accum[i-1] + hdr.buckets[i]
-		for float64(accum[i]) > percentiles[percentileI]*float64(hdr.count) {
-			// linear interpolation
-			down := hdr.granularity * float64(i-1)
-
-			res[percentiles[percentileI]] = down + hdr.granularity/float64(hdr.buckets[i])*(percentiles[percentileI]*float64(hdr.count)-float64(accum[i-1]))
-			percentileI++
-			if percentileI >= len(percentiles) {
-				return res
-			}
-		}
+// classKeeper returns the keeper of the requests of a class
+func (b *BookKeeper) classKeeper(class string) *BookKeeper {
+	if c, ok := b.byClass[class]; ok {
+		return c
 	}
-	return res
+	if b.byClass == nil {
+		b.byClass = make(map[string]*BookKeeper)
+	}
+	// The relative error was validated by NewBookKeeper
+	c, _ := NewBookKeeper(b.relErr)
+	c.genericKeeper = b.genericKeeper
+	c.name = b.name + "/" + class
+	c.percentiles = b.percentiles
+	b.byClass[class] = c
+	return c
 }
 
-func (hdr *histogram) printPercentiles() {
-	percentiles := hdr.getPercentiles()
-	vals := []float64{0.5, 0.9, 0.95, 0.99}
-	for _, v := range vals {
-		fmt.Printf("%vth: %v\t", int(v*100.0), percentiles[v])
+func (b *BookKeeper) record(req engine.ReqInterface) {
+	d := req.GetDelay()
+	b.latency.Add(d)
+	if stealable, ok := req.(*StealableReq); ok && stealable.stolen {
+		b.stolenCount++
+	}
+	if r, ok := req.(TrackedReq); ok {
+		st := r.OriginalServiceTime()
+		b.serviceSum += st
+		b.preemptions += r.Preemptions()
+		b.queueDelay.Add(r.WaitTime())
+		if st > 0 {
+			b.slowdown.Add(d / st)
+		}
 	}
-	fmt.Println()
+}
 
-	fmt.Printf("Req/time_unit:%v\n", float64(hdr.count)/engine.GetTime())
+// Count returns the number of terminated requests
+func (b *BookKeeper) Count() int {
+	return b.latency.Count()
 }
 
-// BookKeeper uses buckets to keep the information
-type BookKeeper struct {
-	hdr  *histogram
-	name string
+// Histograms returns the histograms of the latencies, queueing delays and
+// slowdowns
+func (b *BookKeeper) Histograms() (latency, queueDelay, slowdown *Histogram) {
+	return b.latency, b.queueDelay, b.slowdown
 }
 
-// NewBookKeeper returns a new *BookKeeper
-func NewBookKeeper() *BookKeeper {
-	return &BookKeeper{
-		hdr: newHistogram(),
+// Merge adds the statistics of o to b, e.g. to combine the drains of several
+// processors
+func (b *BookKeeper) Merge(o *BookKeeper) error {
+	for _, h := range [][2]*Histogram{{b.latency, o.latency},
+		{b.queueDelay, o.queueDelay}, {b.slowdown, o.slowdown}} {
+		if err := h[0].Merge(h[1]); err != nil {
+			return err
+		}
+	}
+	b.serviceSum += o.serviceSum
+	b.preemptions += o.preemptions
+	b.stolenCount += o.stolenCount
+	for class, oc := range o.byClass {
+		if err := b.classKeeper(class).Merge(oc); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// SetName gives a name to the particular AllKeeper
-func (b *BookKeeper) SetName(name string) {
-	b.name = name
+// GetSummary returns the statistics collected so far, like
+// AllKeeper.GetSummary
+func (b *BookKeeper) GetSummary() Summary {
+	qs := b.quantiles()
+	res := Summary{
+		Name:                  b.name,
+		Count:                 b.latency.Count(),
+		Stolen:                b.stolenCount,
+		Percentiles:           percentileMetrics("", qs, b.latency.Quantiles(qs)),
+		QueueDelayPercentiles: percentileMetrics("", qs, b.queueDelay.Quantiles(qs)),
+		SlowdownPercentiles:   percentileMetrics("", qs, b.slowdown.Quantiles(qs)),
+	}
+	if t := b.sim.MeasurementTime(); t > 0 {
+		res.Throughput = float64(res.Count) / t
+	}
+	if res.Count > 0 {
+		res.Avg = b.latency.Mean()
+		res.StdDev = b.latency.StdDev()
+		percentiles := b.latency.Quantiles(defaultPercentiles)
+		res.P50 = percentiles[0]
+		res.P90 = percentiles[1]
+		res.P95 = percentiles[2]
+		res.P99 = percentiles[3]
+	}
+	if n := b.queueDelay.Count(); n > 0 {
+		res.ServiceTime = b.serviceSum / float64(n)
+		res.Preemptions = float64(b.preemptions) / float64(n)
+		res.QueueDelay = b.queueDelay.Mean()
+	}
+	if b.slowdown.Count() > 0 {
+		res.Slowdown = b.slowdown.Mean()
+	}
+	for _, class := range requestClasses(b.byClass) {
+		res.PerClass = append(res.PerClass, b.byClass[class].GetSummary())
+	}
+	return res
 }
 
-// TerminateReq is the function called by the processor after finishing
-// request processing
-func (b *BookKeeper) TerminateReq(req engine.ReqInterface) {
-	d := req.GetDelay()
-	b.hdr.addSample(d)
+// Record returns the statistics collected so far as a Record. The metrics of
+// every request class follow, prefixed with <class>/.
+func (b *BookKeeper) Record() Record {
+	sum := b.GetSummary()
+	metrics := summaryMetrics(sum)
+	for i, class := range requestClasses(b.byClass) {
+		metrics = append(metrics, prefixed(class+"/", summaryMetrics(sum.PerClass[i]))...)
+	}
+	return Record{
+		Collector: sum.Name,
+		Type:      "book",
+		Metrics:   metrics,
+	}
 }
 
 // PrintStats prints the collected statistics at the end of the similation.
 // This is called by the model
-func (b *BookKeeper) PrintStats() {
-	fmt.Printf("Stats collector: %v\n", b.name)
-	fmt.Printf("Count\tAVG\tSTDDev\t50th\t90th\t95th\t99th Reqs/time_unit\n")
-	fmt.Printf("%v\t%v\t%v\t", b.hdr.count, b.hdr.avg(), b.hdr.stddev())
-
-	vals := []float64{0.5, 0.9, 0.95, 0.99}
-	percentiles := b.hdr.getPercentiles()
-	for _, v := range vals {
-		fmt.Printf("%v\t", percentiles[v])
+func (b *BookKeeper) PrintStats(w io.Writer) {
+	sum := b.GetSummary()
+	qs := b.quantiles()
+	fmt.Fprintf(w, "Stats collector: %v\n", sum.Name)
+	printSummary(w, sum, qs)
+	for _, c := range sum.PerClass {
+		fmt.Fprintf(w, "Request class: %v\n", c.Name)
+		printSummary(w, c, qs)
 	}
-	fmt.Printf("%v\n", float64(b.hdr.count)/engine.GetTime())
 }
@@ -0,0 +1,185 @@
+package blocks_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// Requests served alone by a time sharing processor are preempted after
+// every full quantum but never wait, whatever the scheduling overhead
+func TestTimeSharingPreemptions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		serviceTime, quantum, ctxCost float64
+		preemptions                   float64
+	}{
+		{50, 10, 0, 4},
+		{50, 10, 1, 4},
+		{55, 10, 2, 5},
+		{50, 50, 1, 0},
+		{50, 100, 0, 0},
+	}
+	for _, tt := range tests {
+		sim, stats := newTestSim(engine.Coroutines)
+		ts := blocks.NewTSProcessor(tt.quantum)
+		ts.SetCtxCost(tt.ctxCost)
+		// One request every 200, each alone in the queue
+		connect(sim, stats, blocks.NewDDGenerator(200, tt.serviceTime), []blocks.Processor{ts})
+		sim.Run(10000)
+
+		sum := stats.GetSummary()
+		if sum.Count == 0 {
+			t.Fatal("no request completed")
+		}
+		if sum.Preemptions != tt.preemptions {
+			t.Errorf("%+v: %v preemptions per request, want %v", tt, sum.Preemptions, tt.preemptions)
+		}
+		if sum.QueueDelay != 0 {
+			t.Errorf("%+v: queueing delay %v, want 0", tt, sum.QueueDelay)
+		}
+		slices := tt.preemptions + 1
+		if want := tt.serviceTime + slices*tt.ctxCost; sum.Avg != want {
+			t.Errorf("%+v: latency %v, want %v", tt, sum.Avg, want)
+		}
+	}
+}
+
+// The queueing delay is the time requests spend queued, so scheduling
+// overheads are not part of it
+func TestQueueDelayIsWaitTime(t *testing.T) {
+	t.Parallel()
+	sim, stats := newTestSim(engine.Coroutines)
+	p := rtc()
+	p.SetCtxCost(1)
+	// Every 100, a request of 10 that waits for nothing
+	connect(sim, stats, blocks.NewDDGenerator(100, 10), []blocks.Processor{p})
+	sim.Run(10000)
+	sum := stats.GetSummary()
+	if sum.QueueDelay != 0 || sum.Avg != 11 {
+		t.Errorf("queueing delay %v and latency %v, want 0 and 11", sum.QueueDelay, sum.Avg)
+	}
+}
+
+// BookKeepers report every request class like AllKeepers
+func TestBookKeeperRequestClasses(t *testing.T) {
+	t.Parallel()
+	var workloads []blocks.Workload
+	for _, s := range []string{"short=0.9@deterministic(value=10)", "long=0.1@exponential(lambda=0.01)"} {
+		w, err := blocks.ParseWorkload(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		workloads = append(workloads, w)
+	}
+	run := func(stats blocks.StatsDrain) blocks.Summary {
+		sim := engine.InitSim(1)
+		sim.InitStats(stats)
+		connect(sim, stats, blocks.NewMixGenerator(0.02, workloads), []blocks.Processor{rtc(), rtc()})
+		sim.Run(100000)
+		return stats.(blocks.Summarizer).GetSummary()
+	}
+	all := run(&blocks.AllKeeper{})
+	book, err := blocks.NewBookKeeper(0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := run(book)
+
+	if len(got.PerClass) != 2 || len(all.PerClass) != 2 {
+		t.Fatalf("%v and %v request classes, want 2", len(got.PerClass), len(all.PerClass))
+	}
+	for i, want := range all.PerClass {
+		c := got.PerClass[i]
+		if c.Count != want.Count || math.Abs(c.Avg-want.Avg) > 1e-9*want.Avg {
+			t.Errorf("class %v: count %v, avg %v, want class %v: count %v, avg %v",
+				c.Name, c.Count, c.Avg, want.Name, want.Count, want.Avg)
+		}
+		if rel := math.Abs(c.P99-want.P99) / want.P99; rel > 0.002 {
+			t.Errorf("class %v: p99 %v, want %v", c.Name, c.P99, want.P99)
+		}
+	}
+	if got.Count != got.PerClass[0].Count+got.PerClass[1].Count {
+		t.Errorf("%v requests, but %v and %v per class", got.Count, got.PerClass[0].Count, got.PerClass[1].Count)
+	}
+}
+
+// Requests born before the end of the warm-up are left out, and throughput
+// is measured from its end
+func TestWarmup(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		time  float64
+		count int
+		end   float64
+		n     int // requests measured
+	}{
+		{0, 0, 0, 50},
+		{1050, 0, 1050, 39},
+		{1000, 0, 1000, 40},
+		// The 10th request completes at 910
+		{0, 10, 910, 40},
+		{500, 10, 910, 40},
+		{2000, 10, 2000, 30},
+	}
+	for _, tt := range tests {
+		// Every 100, a request of 10, the last at 4900
+		sim, stats := newTestSim(engine.Coroutines)
+		sim.SetWarmupTime(tt.time)
+		sim.SetWarmupCount(tt.count)
+		connect(sim, stats, blocks.NewDDGenerator(100, 10), []blocks.Processor{rtc()})
+		sim.Run(5000)
+
+		end, over := sim.WarmupEnd()
+		if !over || end != tt.end {
+			t.Errorf("%+v: warm-up over %v at %v", tt, over, end)
+		}
+		sum := stats.GetSummary()
+		if sum.Count != tt.n {
+			t.Errorf("%+v: %v requests measured, want %v", tt, sum.Count, tt.n)
+		}
+		if want := float64(tt.n) / (5000 - tt.end); sum.Throughput != want {
+			t.Errorf("%+v: throughput %v, want %v", tt, sum.Throughput, want)
+		}
+	}
+}
+
+// Service time classes use the original service time of the requests, even
+// when time sharing consumes it in slices
+func TestServiceTimeClasses(t *testing.T) {
+	t.Parallel()
+	g, err := blocks.NewGenerator("dist", blocks.Params{"lambda": 0.01,
+		"interarrival": "deterministic(value=1)", "service": "bimodal(v1=10;v2=30;ratio=0.8)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, stats := newTestSim(engine.Coroutines)
+	stats.SetServiceClasses([]float64{100, 20})
+	connect(sim, stats, g, []blocks.Processor{blocks.NewTSProcessor(5)})
+	sim.Run(100000)
+
+	sum := stats.GetSummary()
+	want := []struct {
+		name string
+		avg  float64
+	}{{"st0-20", 10}, {"st20-100", 30}, {"st100+", 0}}
+	if len(sum.Classes) != len(want) {
+		t.Fatalf("classes %+v, want %+v", sum.Classes, want)
+	}
+	count := 0
+	for i, w := range want {
+		c := sum.Classes[i]
+		count += c.Count
+		if c.Name != w.name || c.Avg != w.avg {
+			t.Errorf("class %v: %v with latency %v, want %v with %v", i, c.Name, c.Avg, w.name, w.avg)
+		}
+		if c.Count > 0 && c.Slowdown != 1 {
+			t.Errorf("class %v: slowdown %v, want 1", c.Name, c.Slowdown)
+		}
+	}
+	if count != sum.Count || sum.Classes[2].Count != 0 {
+		t.Errorf("%v requests in the classes %+v, want %v", count, sum.Classes, sum.Count)
+	}
+}
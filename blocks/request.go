@@ -6,16 +6,97 @@ import (
 	"github.com/epfl-dcsl/schedsim/engine"
 )
 
-// Request is the basic request type
+// Request is the basic request type. ServiceTime is the remaining service
+// time, reduced by preemptive processors. The rest of the life cycle of the
+// request is recorded by the processors and the drains, see TrackedReq.
 type Request struct {
 	InitTime    float64
 	ServiceTime float64
+	sim         *engine.Simulation
+
+	origServiceTime float64
+	readyTime       float64 // last time the request was queued
+	waitTime        float64
+	preemptions     int
+	class           string
+}
+
+func newRequest(sim *engine.Simulation, serviceTime float64) Request {
+	now := sim.GetTime()
+	return Request{InitTime: now, ServiceTime: serviceTime, sim: sim,
+		origServiceTime: serviceTime, readyTime: now}
+}
+
+// TrackedReq is a request recording its life cycle
+type TrackedReq interface {
+	engine.ReqInterface
+	ArrivalTime() float64
+	OriginalServiceTime() float64
+	WaitTime() float64
+	Preemptions() int
+}
+
+// classedReq is a request that belongs to a class of requests, set by the
+// generator. Drains report statistics per class.
+type classedReq interface {
+	Class() string
+	setClass(class string)
+}
+
+// Class returns the class of the request, empty if it has none
+func (r Request) Class() string {
+	return r.class
+}
+
+func (r *Request) setClass(class string) {
+	r.class = class
+}
+
+// lifecycle is implemented by the requests that record their life cycle.
+// Processors report when they start and stop serving a request.
+type lifecycle interface {
+	startService(t float64)
+	stopService(t float64, preempted bool)
 }
 
 // GetDelay returns the request latency from the time it was sent till the time
 // processing was over
 func (r Request) GetDelay() float64 {
-	return engine.GetTime() - r.InitTime
+	return r.sim.GetTime() - r.InitTime
+}
+
+// ArrivalTime returns the time the request was created
+func (r Request) ArrivalTime() float64 {
+	return r.InitTime
+}
+
+// OriginalServiceTime returns the service time of the request before any
+// processing
+func (r Request) OriginalServiceTime() float64 {
+	return r.origServiceTime
+}
+
+// WaitTime returns the time the request spent queued, waiting for service.
+// Scheduling overheads count as service, and preempted requests wait again
+// once back in a queue.
+func (r Request) WaitTime() float64 {
+	return r.waitTime
+}
+
+// Preemptions returns how many times the request was preempted
+func (r Request) Preemptions() int {
+	return r.preemptions
+}
+
+func (r *Request) startService(t float64) {
+	r.waitTime += t - r.readyTime
+}
+
+func (r *Request) stopService(t float64, preempted bool) {
+	r.readyTime = t
+	if preempted {
+		r.preemptions++
+	}
 }
 
 // GetServiceTime returns the request service time
@@ -54,37 +135,89 @@ type ColoredReq struct {
 	color int
 }
 
+func init() {
+	RegisterCreator("simple", "plain requests", nil,
+		func(sim *engine.Simulation, p Params) ReqCreator {
+			return NewSimpleReqCreator(sim)
+		})
+	RegisterCreator("stealable", "requests accounting for steals", nil,
+		func(sim *engine.Simulation, p Params) ReqCreator {
+			return NewStealableReqCreator(sim)
+		})
+	RegisterCreator("monitor", "requests recording queue lengths", nil,
+		func(sim *engine.Simulation, p Params) ReqCreator {
+			return NewMonitorReqCreator(sim)
+		})
+	RegisterCreator("colored", "requests with a random color", nil,
+		func(sim *engine.Simulation, p Params) ReqCreator {
+			return NewColoredReqCreator(sim)
+		})
+}
+
 // ReqCreator is a used by generators to create the appropriate type of requests
 type ReqCreator interface {
 	NewRequest(serviceTime float64) engine.ReqInterface
 }
 
 // SimpleReqCreator creates structs of type Request
-type SimpleReqCreator struct{}
+type SimpleReqCreator struct {
+	sim *engine.Simulation
+}
+
+// NewSimpleReqCreator returns a new *SimpleReqCreator bound to sim
+func NewSimpleReqCreator(sim *engine.Simulation) *SimpleReqCreator {
+	return &SimpleReqCreator{sim: sim}
+}
 
 // NewRequest returns a new Request struct
-func (rc SimpleReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}
+func (rc *SimpleReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
+	r := newRequest(rc.sim, serviceTime)
+	return &r
 }
 
 // StealableReqCreator creates structs of type StealableReq
-type StealableReqCreator struct{}
+type StealableReqCreator struct {
+	sim *engine.Simulation
+}
+
+// NewStealableReqCreator returns a new *StealableReqCreator bound to sim
+func NewStealableReqCreator(sim *engine.Simulation) *StealableReqCreator {
+	return &StealableReqCreator{sim: sim}
+}
 
 // NewRequest returns a new StealableReq struct
-func (rc StealableReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &StealableReq{Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}, false}
+func (rc *StealableReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
+	return &StealableReq{newRequest(rc.sim, serviceTime), false}
 }
 
 // MonitorReqCreator creates structs of type MonitorReq
-type MonitorReqCreator struct{}
+type MonitorReqCreator struct {
+	sim *engine.Simulation
+}
+
+// NewMonitorReqCreator returns a new *MonitorReqCreator bound to sim
+func NewMonitorReqCreator(sim *engine.Simulation) *MonitorReqCreator {
+	return &MonitorReqCreator{sim: sim}
+}
 
 // NewRequest returns a new MonitorReq struct
-func (rc MonitorReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &MonitorReq{Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}, 0, 0}
+func (rc *MonitorReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
+	return &MonitorReq{newRequest(rc.sim, serviceTime), 0, 0}
+}
+
+// ColoredReqCreator creates structs of type ColoredReq with a random color
+type ColoredReqCreator struct {
+	sim *engine.Simulation
+	rng *rand.Rand
 }
 
-type ColoredReqCreator struct{}
+// NewColoredReqCreator returns a new *ColoredReqCreator bound to sim and
+// drawing colors from its own random stream
+func NewColoredReqCreator(sim *engine.Simulation) *ColoredReqCreator {
+	return &ColoredReqCreator{sim: sim, rng: sim.NewRand()}
+}
 
-func (rc ColoredReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
-	return &ColoredReq{Request{InitTime: engine.GetTime(), ServiceTime: serviceTime}, rand.Int() % 2}
+// NewRequest returns a new ColoredReq struct
+func (rc *ColoredReqCreator) NewRequest(serviceTime float64) engine.ReqInterface {
+	return &ColoredReq{newRequest(rc.sim, serviceTime), rc.rng.Intn(2)}
 }
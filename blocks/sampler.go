@@ -0,0 +1,98 @@
+package blocks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// BusyReporter is implemented by the actors that can tell if they are busy,
+// like the processors
+type BusyReporter interface {
+	Busy() bool
+}
+
+// Sampler is an actor that records the state of the simulation at a fixed
+// simulated-time interval, as CSV rows with the time, the length of every
+// queue, the busy (1) or idle (0) state of every processor and the number
+// of completions and the throughput since the previous sample. Queues and
+// processors are numbered in registration order.
+//
+// The sampler only reads the simulation state and should be registered
+// after the topology, so sampling changes neither the random streams nor the
+// order of the other events of a run. It samples only before the threshold
+// time of the run, as an event at the threshold would end the run there, and
+// leaves the simulation once its next sample would pass the threshold.
+type Sampler struct {
+	engine.Actor
+	sim         *engine.Simulation
+	interval    float64
+	threshold   float64
+	w           *csv.Writer
+	processors  []BusyReporter
+	completions int
+}
+
+// NewSampler returns a sampler of sim writing a sample every interval to w,
+// for a run with the given threshold time
+func NewSampler(sim *engine.Simulation, interval, threshold float64, w io.Writer) *Sampler {
+	return &Sampler{sim: sim, interval: interval, threshold: threshold, w: csv.NewWriter(w)}
+}
+
+func (s *Sampler) writeHeader() {
+	header := []string{"time"}
+	for i := range s.sim.Queues() {
+		header = append(header, fmt.Sprintf("q%v", i))
+	}
+	for _, a := range s.sim.Actors() {
+		if p, ok := a.(BusyReporter); ok {
+			header = append(header, fmt.Sprintf("p%v_busy", len(s.processors)))
+			s.processors = append(s.processors, p)
+		}
+	}
+	header = append(header, "completions", "reqs_per_time_unit")
+	s.w.Write(header)
+}
+
+func (s *Sampler) sample() {
+	ftoa := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	row := []string{ftoa(s.GetTime())}
+	for _, q := range s.sim.Queues() {
+		row = append(row, strconv.Itoa(q.Len()))
+	}
+	for _, p := range s.processors {
+		busy := "0"
+		if p.Busy() {
+			busy = "1"
+		}
+		row = append(row, busy)
+	}
+	completions := s.sim.Completions() - s.completions
+	s.completions += completions
+	row = append(row, strconv.Itoa(completions),
+		ftoa(float64(completions)/s.interval))
+	s.w.Write(row)
+}
+
+// Run is the main loop of the sampler
+func (s *Sampler) Run() {
+	s.writeHeader()
+	for {
+		s.sample()
+		if s.GetTime()+s.interval >= s.threshold {
+			return
+		}
+		s.Wait(s.interval)
+	}
+}
+
+// Flush writes the buffered samples and returns any write error
+func (s *Sampler) Flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
@@ -0,0 +1,50 @@
+package blocks_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// sampledMM1 runs an M/M/1 queue, sampled every interval if it is positive,
+// and returns the simulation, the summary of the drain and the samples
+func sampledMM1(mode engine.ExecMode, duration, interval float64) (*engine.Simulation, blocks.Summary, string) {
+	sim, stats := newTestSim(mode)
+	connect(sim, stats, blocks.NewMMRandGenerator(0.01, 0.02), []blocks.Processor{rtc()})
+
+	var buf bytes.Buffer
+	var sampler *blocks.Sampler
+	if interval > 0 {
+		sampler = blocks.NewSampler(sim, interval, duration, &buf)
+		sim.RegisterActor(sampler)
+	}
+	sim.Run(duration)
+	if sampler != nil {
+		sampler.Flush()
+	}
+	return sim, stats.GetSummary(), buf.String()
+}
+
+// Sampling should change neither the statistics nor the stop time of a run,
+// even with samples falling on the threshold
+func TestSamplerKeepsRun(t *testing.T) {
+	t.Parallel()
+	for _, mode := range []engine.ExecMode{engine.Goroutines, engine.Coroutines} {
+		plain, want, _ := sampledMM1(mode, 10000, 0)
+		sampled, got, samples := sampledMM1(mode, 10000, 100)
+		if plain.GetTime() != sampled.GetTime() {
+			t.Errorf("mode %v: stop time %v, want %v", mode, sampled.GetTime(), plain.GetTime())
+		}
+		if got.Count != want.Count || got.Avg != want.Avg {
+			t.Errorf("mode %v: %v requests, average %v, want %v and %v",
+				mode, got.Count, got.Avg, want.Count, want.Avg)
+		}
+		// Header and the samples at 0, 100, ..., 9900
+		if rows := strings.Count(samples, "\n"); rows != 101 {
+			t.Errorf("mode %v: %v sample rows, want 101", mode, rows)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package blocks
+
+import (
+	"fmt"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+func init() {
+	RegisterProcessor("steal", "run to completion, steals from other queues when its own is empty",
+		[]Param{ctxCostParam,
+			{"victim", VictimParam, "random", "queue to steal from: random, roundrobin or longest"},
+			{"stealCost", FloatParam, 0.0, "overhead added to every stolen request"}},
+		func(p Params) Processor {
+			sp := NewStealingProcessor(p.Victim("victim"), p.Float("stealCost"))
+			sp.SetCtxCost(p.Float("ctxCost"))
+			return sp
+		})
+}
+
+// VictimPolicy selects the queue a stealing processor steals from
+type VictimPolicy int
+
+// Victim selection policies
+const (
+	RandomVictim     VictimPolicy = iota // a random non-empty queue
+	RoundRobinVictim                     // the next non-empty queue after the last victim
+	LongestVictim                        // the longest queue, the first one on ties
+)
+
+var victimPolicies = []string{"random", "roundrobin", "longest"}
+
+func (v VictimPolicy) String() string {
+	if v < 0 || int(v) >= len(victimPolicies) {
+		return "unknown"
+	}
+	return victimPolicies[v]
+}
+
+// ParseVictimPolicy parses a victim policy by name
+func ParseVictimPolicy(s string) (VictimPolicy, error) {
+	for i, name := range victimPolicies {
+		if s == name {
+			return VictimPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown victim policy: %v", s)
+}
+
+// Stealer is implemented by the processors that steal requests from the
+// queues of other processors. The owners are the processors serving q.
+type Stealer interface {
+	AddVictimQueue(q engine.QueueInterface, owners ...BusyReporter)
+}
+
+// StealingProcessor is a run to completion processor that serves its own
+// queue, its first input queue, and steals from the queues of the other
+// processors, its victim queues, when its own queue is empty. It only
+// steals requests waiting behind busy owners: a request arriving at an idle
+// owner is left to it. Stolen requests pay the steal cost as scheduling
+// overhead and are marked as stolen if they are StealableReqs.
+type StealingProcessor struct {
+	genericProcessor
+	victim     VictimPolicy
+	stealCost  float64
+	lastVictim int
+	owners     map[engine.QueueInterface][]BusyReporter
+}
+
+// NewStealingProcessor returns a new *StealingProcessor
+func NewStealingProcessor(victim VictimPolicy, stealCost float64) *StealingProcessor {
+	return &StealingProcessor{victim: victim, stealCost: stealCost}
+}
+
+// AddVictimQueue adds a queue to steal from, served by the given owners.
+// The own queue should be added first, with AddInQueue.
+func (p *StealingProcessor) AddVictimQueue(q engine.QueueInterface, owners ...BusyReporter) {
+	if p.owners == nil {
+		p.owners = make(map[engine.QueueInterface][]BusyReporter)
+	}
+	p.owners[q] = owners
+	p.AddInQueue(q)
+}
+
+// stealable returns true if q is a victim queue whose requests wait behind
+// busy owners. Queues without owners can always be stolen from.
+func (p *StealingProcessor) stealable(q engine.QueueInterface) bool {
+	if q.Len() == 0 {
+		return false
+	}
+	owners, ok := p.owners[q]
+	if !ok {
+		// The own queue
+		return false
+	}
+	for _, o := range owners {
+		if !o.Busy() {
+			return false
+		}
+	}
+	return true
+}
+
+// wakes returns true if q not being empty should wake up the idle processor
+func (p *StealingProcessor) wakes(q engine.QueueInterface) bool {
+	return q == p.GetInQueue(0) || p.stealable(q)
+}
+
+// chooseVictim returns the index of the input queue to steal from, or zero
+// if no victim queue is stealable
+func (p *StealingProcessor) chooseVictim() int {
+	lens := p.GetAllInQueueLens()
+	var available []int
+	for i := 1; i < len(lens); i++ {
+		if p.stealable(p.GetInQueue(i)) {
+			available = append(available, i)
+		}
+	}
+	if len(available) == 0 {
+		return 0
+	}
+	switch p.victim {
+	case RoundRobinVictim:
+		for i := 1; i < len(lens); i++ {
+			idx := (p.lastVictim+i-1)%(len(lens)-1) + 1
+			if p.stealable(p.GetInQueue(idx)) {
+				p.lastVictim = idx
+				return idx
+			}
+		}
+	case LongestVictim:
+		res := available[0]
+		for _, i := range available {
+			if lens[i] > lens[res] {
+				res = i
+			}
+		}
+		return res
+	}
+	return available[p.Rand().Intn(len(available))]
+}
+
+// next returns the next request to serve and whether it was stolen. If
+// there is none it waits for a request in its own queue or a stealable one.
+func (p *StealingProcessor) next() (engine.ReqInterface, bool) {
+	for {
+		if p.GetInQueueLen(0) > 0 {
+			return p.ReadInQueue(), false
+		}
+		if idx := p.chooseVictim(); idx > 0 {
+			return p.ReadInQueueI(idx), true
+		}
+		p.WaitInQueuesIf(p.wakes)
+	}
+}
+
+// Run is the main processor loop
+func (p *StealingProcessor) Run() {
+	for {
+		req, stolen := p.next()
+		p.startService(req)
+		overhead := p.ctxCost
+		if stolen {
+			overhead += p.stealCost
+			if r, ok := req.(*StealableReq); ok {
+				r.stolen = true
+			}
+		}
+		p.serve(req.GetServiceTime(), overhead)
+		p.terminate(req)
+	}
+}
@@ -0,0 +1,49 @@
+package blocks_test
+
+import (
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// multiQueueStealing runs a generator feeding one queue per core, served by
+// stealing processors, and returns the summary of the drain
+func multiQueueStealing(lambda, mu float64, cores int, duration float64, mode engine.ExecMode) blocks.Summary {
+	sim, stats := newTestSim(mode)
+	g := blocks.NewMMRandGenerator(lambda, mu)
+	procs := make([]*blocks.StealingProcessor, cores)
+	servers := make([][]blocks.Processor, cores)
+	for i := range procs {
+		procs[i] = blocks.NewStealingProcessor(blocks.RandomVictim, 0)
+		servers[i] = []blocks.Processor{procs[i]}
+	}
+	queues := connect(sim, stats, g, servers...)
+	for i, p := range procs {
+		for j := 1; j < cores; j++ {
+			victim := (i + j) % cores
+			p.AddVictimQueue(queues[victim], procs[victim])
+		}
+	}
+	g.SetCreator(blocks.NewStealableReqCreator(sim))
+
+	sim.Run(duration)
+	return stats.GetSummary()
+}
+
+// At low load requests almost never arrive at a busy core, so they should
+// almost never be stolen
+func TestStealingLowLoad(t *testing.T) {
+	t.Parallel()
+	for _, mode := range []engine.ExecMode{engine.Goroutines, engine.Coroutines} {
+		// 5% load over 4 cores
+		sum := multiQueueStealing(0.004, 0.02, 4, 500000, mode)
+		if sum.Count == 0 {
+			t.Fatal("no request completed")
+		}
+		// A request finds its core busy with probability 0.05
+		if frac := float64(sum.Stolen) / float64(sum.Count); frac > 0.08 {
+			t.Errorf("mode %v: %v of %v requests stolen", mode, sum.Stolen, sum.Count)
+		}
+	}
+}
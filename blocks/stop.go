@@ -0,0 +1,101 @@
+package blocks
+
+import (
+	"math"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// Number of batches used to estimate confidence intervals within a run
+const ciBatches = 30
+
+// countStop is met when the drains terminated enough requests, in total or
+// each one of them
+type countStop struct {
+	drains   []RequestDrain
+	n        int
+	perDrain bool
+}
+
+// NewCountStop returns a stop condition met when the given drains
+// terminated n requests in total
+func NewCountStop(n int, drains ...RequestDrain) engine.StopCondition {
+	return &countStop{drains: drains, n: n}
+}
+
+// NewPerDrainCountStop returns a stop condition met when every one of the
+// given drains terminated n requests
+func NewPerDrainCountStop(n int, drains ...RequestDrain) engine.StopCondition {
+	return &countStop{drains: drains, n: n, perDrain: true}
+}
+
+func (c *countStop) Done() bool {
+	total := 0
+	for _, d := range c.drains {
+		if c.perDrain && d.Count() < c.n {
+			return false
+		}
+		total += d.Count()
+	}
+	return c.perDrain || total >= c.n
+}
+
+// ciStop is met when the confidence interval of a latency metric of an
+// AllKeeper is narrow enough
+type ciStop struct {
+	k         *AllKeeper
+	quantile  float64
+	relWidth  float64
+	nextCheck int
+}
+
+// NewCIStop returns a stop condition met when the half width of the 95%
+// confidence interval of a latency metric of k falls below relWidth times
+// the metric. The metric is the mean if quantile is 0, or the given quantile
+// (e.g. 0.99) otherwise. The interval is computed with batch means, to
+// account for the correlation between consecutive requests.
+func NewCIStop(k *AllKeeper, quantile, relWidth float64) engine.StopCondition {
+	// Every batch should see a few samples above the quantile
+	batchSize := 10
+	if quantile > 0 {
+		batchSize = int(math.Ceil(10 / (1 - quantile)))
+	}
+	return &ciStop{
+		k:         k,
+		quantile:  quantile,
+		relWidth:  relWidth,
+		nextCheck: batchSize * ciBatches,
+	}
+}
+
+func (c *ciStop) Done() bool {
+	n := c.k.Count()
+	if n < c.nextCheck {
+		return false
+	}
+	// Checking is linear or worse in the samples so check again after 10%
+	// more samples
+	c.nextCheck = n + n/10
+
+	est, halfWidth := c.estimate()
+	return halfWidth <= c.relWidth*math.Abs(est)
+}
+
+// estimate returns the metric and the half width of its confidence interval
+func (c *ciStop) estimate() (float64, float64) {
+	items := c.k.items
+	batchSize := len(items) / ciBatches
+	batches := make([]float64, ciBatches)
+	for i := range batches {
+		batch := items[i*batchSize : (i+1)*batchSize]
+		if c.quantile > 0 {
+			batches[i] = quantile(batch, c.quantile)
+			continue
+		}
+		for _, v := range batch {
+			batches[i] += v
+		}
+		batches[i] /= float64(batchSize)
+	}
+	return MeanCI(batches)
+}
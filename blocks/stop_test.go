@@ -0,0 +1,79 @@
+package blocks_test
+
+import (
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+func TestCountStop(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		perDrain bool
+		n        int
+		a, b     int // requests terminated by each drain
+	}{
+		{false, 20, 10, 10},
+		{false, 21, 11, 10},
+		{true, 20, 20, 20},
+	}
+	for _, tt := range tests {
+		// One request every 100, alternating between the two drains
+		sim, a := newTestSim(engine.Coroutines)
+		b := &blocks.AllKeeper{}
+		sim.InitStats(b)
+		second := rtc()
+		connect(sim, a, blocks.NewDDGenerator(100, 10), []blocks.Processor{rtc()},
+			[]blocks.Processor{second})
+		second.SetReqDrain(b)
+		if tt.perDrain {
+			sim.AddStopCondition(blocks.NewPerDrainCountStop(tt.n, a, b))
+		} else {
+			sim.AddStopCondition(blocks.NewCountStop(tt.n, a, b))
+		}
+		sim.Run(1e6)
+
+		if a.Count() != tt.a || b.Count() != tt.b {
+			t.Errorf("%+v: the drains terminated %v and %v requests, want %v and %v",
+				tt, a.Count(), b.Count(), tt.a, tt.b)
+		}
+	}
+}
+
+// The run stops once the confidence interval of the metric is narrow
+// enough, long before the maximum duration, and later for narrower
+// intervals. Every check needs 30 batches of at least 10 samples, or 10
+// above the quantile.
+func TestCIStop(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		quantile   float64
+		minSamples int
+	}{
+		{0, 300},
+		{0.9, 3000},
+	}
+	for _, tt := range tests {
+		var prev float64
+		for _, relWidth := range []float64{0.1, 0.05, 0.02} {
+			sim, stats := newTestSim(engine.Coroutines)
+			connect(sim, stats, blocks.NewMMRandGenerator(0.01, 0.02), []blocks.Processor{rtc()})
+			sim.AddStopCondition(blocks.NewCIStop(stats, tt.quantile, relWidth))
+			const duration = 1e9
+			sim.Run(duration)
+
+			if sim.GetTime() >= duration {
+				t.Errorf("%+v, width %v: the run did not stop early", tt, relWidth)
+			}
+			if stats.Count() < tt.minSamples {
+				t.Errorf("%+v, width %v: stopped after %v samples", tt, relWidth, stats.Count())
+			}
+			if sim.GetTime() <= prev {
+				t.Errorf("%+v, width %v: stopped at %v, not after the wider interval at %v",
+					tt, relWidth, sim.GetTime(), prev)
+			}
+			prev = sim.GetTime()
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package blocks
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// Usage is the time a processor spent serving requests (Busy) and in
+// scheduling overhead (Overhead). Processors sharing several cores report
+// the time per core.
+type Usage struct {
+	Cores    int
+	Busy     float64
+	Overhead float64
+}
+
+// UsageReporter is implemented by the processors accounting their time
+type UsageReporter interface {
+	Usage() Usage
+}
+
+// UsageStats reports the busy, overhead and idle time of every processor of
+// the simulation, with its load (busy time over the measured time) and its
+// utilisation (busy and overhead time over the measured time), and the same
+// for all the processors together. Like the queue statistics it covers the
+// run after the warm-up.
+// Dispatchers are left out, they are reported by DispatcherStats.
+type UsageStats struct {
+	sim *engine.Simulation
+}
+
+// NewUsageStats returns a new *UsageStats
+func NewUsageStats() *UsageStats {
+	return &UsageStats{}
+}
+
+// SetSimulation binds the collector to the simulation whose processors it
+// reports. It is called by the simulation when the collector is registered.
+func (u *UsageStats) SetSimulation(s *engine.Simulation) {
+	u.sim = s
+}
+
+// processorUsage is the usage of a processor at the end of the run
+type processorUsage struct {
+	name string
+	Usage
+	idle float64
+}
+
+func (u *processorUsage) load(elapsed float64) float64 {
+	return u.Busy / (elapsed * float64(u.Cores))
+}
+
+func (u *processorUsage) utilisation(elapsed float64) float64 {
+	return (u.Busy + u.Overhead) / (elapsed * float64(u.Cores))
+}
+
+// usages returns the usage of every processor in registration order,
+// followed by the aggregate usage
+func (u *UsageStats) usages() []processorUsage {
+	elapsed := u.sim.MeasurementTime()
+	total := processorUsage{name: "all"}
+	var res []processorUsage
+	for _, a := range u.sim.Actors() {
+		r, ok := a.(UsageReporter)
+		if _, dispatcher := a.(DispatchReporter); !ok || dispatcher {
+			continue
+		}
+		pu := processorUsage{name: fmt.Sprintf("p%v", len(res)), Usage: r.Usage()}
+		pu.idle = elapsed*float64(pu.Cores) - pu.Busy - pu.Overhead
+		res = append(res, pu)
+		total.Cores += pu.Cores
+		total.Busy += pu.Busy
+		total.Overhead += pu.Overhead
+		total.idle += pu.idle
+	}
+	return append(res, total)
+}
+
+// PrintStats prints the usage of the processors at the end of the
+// simulation. This is called by the model
+func (u *UsageStats) PrintStats(w io.Writer) {
+	elapsed := u.sim.MeasurementTime()
+	fmt.Fprintf(w, "Stats collector: Utilisation\n")
+	fmt.Fprintf(w, "Processor\tCores\tBusy\tOverhead\tIdle\tLoad\tUtilisation\n")
+	for _, pu := range u.usages() {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", pu.name, pu.Cores, pu.Busy,
+			pu.Overhead, pu.idle, pu.load(elapsed), pu.utilisation(elapsed))
+	}
+}
+
+// Record returns the usage of the processors as a Record, with metrics
+// prefixed by the processor, and the aggregate usage prefixed by all
+func (u *UsageStats) Record() Record {
+	elapsed := u.sim.MeasurementTime()
+	res := Record{Collector: "Utilisation", Type: "usage"}
+	for _, pu := range u.usages() {
+		prefix := pu.name + "_"
+		res.Metrics = append(res.Metrics,
+			Metric{prefix + "cores", float64(pu.Cores)},
+			Metric{prefix + "busy", pu.Busy},
+			Metric{prefix + "overhead", pu.Overhead},
+			Metric{prefix + "idle", pu.idle},
+			Metric{prefix + "load", pu.load(elapsed)},
+			Metric{prefix + "utilisation", pu.utilisation(elapsed)})
+	}
+	return res
+}
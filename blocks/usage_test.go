@@ -0,0 +1,81 @@
+package blocks_test
+
+import (
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// metric returns the value of the named metric of r
+func metric(t *testing.T, r blocks.Record, name string) float64 {
+	t.Helper()
+	for _, m := range r.Metrics {
+		if m.Name == name {
+			return m.Value
+		}
+	}
+	t.Fatalf("%v: no metric %v", r.Collector, name)
+	return 0
+}
+
+// Requests of 50 every 200, with the warm-up ending at 1025 in the middle
+// of a service period and the run at 10000, when a period starts: 25 + 44*50
+// busy time is measured over 8975
+const (
+	usageWarmup   = 1025
+	usageDuration = 10000
+	usageBusy     = 25 + 44*50
+)
+
+func TestUsageStatsSkipWarmup(t *testing.T) {
+	t.Parallel()
+	procs := map[string]func() blocks.Processor{
+		"rtc": rtc,
+		"ps":  func() blocks.Processor { return blocks.NewPSProcessor() },
+	}
+	for name, newProc := range procs {
+		sim, stats := newTestSim(engine.Coroutines)
+		sim.SetWarmupTime(usageWarmup)
+		usage := blocks.NewUsageStats()
+		sim.InitStats(usage)
+		connect(sim, stats, blocks.NewDDGenerator(200, 50), []blocks.Processor{newProc()})
+		sim.Run(usageDuration)
+
+		r := usage.Record()
+		checkClose(t, name+" busy", metric(t, r, "p0_busy"), usageBusy, 1e-9)
+		checkClose(t, name+" idle", metric(t, r, "p0_idle"), usageDuration-usageWarmup-usageBusy, 1e-9)
+		checkClose(t, name+" load", metric(t, r, "p0_load"),
+			float64(usageBusy)/(usageDuration-usageWarmup), 1e-9)
+	}
+}
+
+func TestDispatcherStatsSkipWarmup(t *testing.T) {
+	t.Parallel()
+	sim, stats := newTestSim(engine.Coroutines)
+	sim.SetWarmupTime(usageWarmup)
+	dstats := blocks.NewDispatcherStats()
+	sim.InitStats(dstats)
+
+	d := blocks.NewCentralDispatcher(1, blocks.JBSQDispatcher{Bound: 1})
+	p := &blocks.RTCProcessor{}
+	central, worker := blocks.NewQueue(), blocks.NewQueue()
+	d.AddInQueue(central)
+	d.AddWorker(worker, p)
+	p.AddInQueue(worker)
+	p.SetReqDrain(stats)
+	sim.RegisterActor(p)
+	sim.RegisterActor(d)
+	g := blocks.NewDDGenerator(200, 50)
+	g.AddOutQueue(central)
+	g.SetCreator(blocks.NewSimpleReqCreator(sim))
+	sim.RegisterActor(g)
+	sim.Run(usageDuration)
+
+	// The decision on the request of 1000 ends at 1001, its completion at 1051
+	r := dstats.Record()
+	checkClose(t, "decisions", metric(t, r, "d0_decisions"), 44, 0)
+	checkClose(t, "notifications", metric(t, r, "d0_notifications"), 45, 0)
+	checkClose(t, "busy", metric(t, r, "d0_busy"), 44, 1e-9)
+	checkClose(t, "throughput", metric(t, r, "d0_throughput"), 44.0/(usageDuration-usageWarmup), 1e-9)
+}
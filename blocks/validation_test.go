@@ -0,0 +1,145 @@
+package blocks_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/analytic"
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// The tests of this file run single queue topologies whose latency has a
+// closed form and check that the simulated values are within a relative
+// tolerance of it. Runs use fixed seeds, so they are reproducible, and a
+// warm-up of 1% of their duration. Every topology runs in both execution
+// modes, which should give identical results.
+
+// singleQueue runs a generator feeding one queue served by cores
+// processors, in both execution modes, and returns the summary of the drain,
+// which reports the given percentiles
+func singleQueue(t *testing.T, newGen func() blocks.Generator, newProc func() blocks.Processor,
+	cores int, duration float64, percentiles ...float64) blocks.Summary {
+	sum := runSingleQueue(engine.Coroutines, newGen(), newProc, cores, duration, percentiles...)
+	if sum.Count == 0 {
+		t.Fatal("no request completed")
+	}
+	other := runSingleQueue(engine.Goroutines, newGen(), newProc, cores, duration, percentiles...)
+	if !reflect.DeepEqual(sum, other) {
+		t.Fatalf("the execution modes differ: %+v and %+v", sum, other)
+	}
+	return sum
+}
+
+func runSingleQueue(mode engine.ExecMode, g blocks.Generator, newProc func() blocks.Processor,
+	cores int, duration float64, percentiles ...float64) blocks.Summary {
+	sim, stats := newTestSim(mode)
+	sim.SetWarmupTime(duration / 100)
+	if len(percentiles) > 0 {
+		stats.SetPercentiles(percentiles)
+	}
+	procs := make([]blocks.Processor, cores)
+	for i := range procs {
+		procs[i] = newProc()
+	}
+	connect(sim, stats, g, procs)
+	sim.Run(duration)
+	return stats.GetSummary()
+}
+
+func rtc() blocks.Processor {
+	return &blocks.RTCProcessor{}
+}
+
+func checkClose(t *testing.T, what string, got, want, tolerance float64) {
+	t.Helper()
+	if err := math.Abs(got-want) / want; err > tolerance {
+		t.Errorf("%v: got %.4g, want %.4g (error %.1f%% > %.1f%%)",
+			what, got, want, 100*err, 100*tolerance)
+	}
+}
+
+func TestMM1(t *testing.T) {
+	t.Parallel()
+	lambda, mu := 0.7, 1.0
+	gen := func() blocks.Generator {
+		return blocks.NewMMRandGenerator(lambda, mu)
+	}
+	sum := singleQueue(t, gen, rtc, 1, 400000)
+
+	// The latency is exponential with rate mu - lambda
+	rate := mu - lambda
+	checkClose(t, "mean latency", sum.Avg, 1/rate, 0.05)
+	checkClose(t, "p50", sum.P50, math.Log(2)/rate, 0.05)
+	checkClose(t, "p90", sum.P90, math.Log(10)/rate, 0.05)
+	checkClose(t, "p99", sum.P99, math.Log(100)/rate, 0.08)
+}
+
+func TestMMc(t *testing.T) {
+	t.Parallel()
+	c, lambda, mu := 4, 3.0, 1.0
+	gen := func() blocks.Generator {
+		return blocks.NewMMRandGenerator(lambda, mu)
+	}
+	sum := singleQueue(t, gen, rtc, c, 200000, 0.99)
+
+	pWait := analytic.ErlangC(c, lambda/mu)
+	rate := float64(c)*mu - lambda
+	checkClose(t, "mean latency", sum.Avg, pWait/rate+1/mu, 0.05)
+	checkClose(t, "mean queueing delay", sum.QueueDelay, pWait/rate, 0.08)
+	// P(wait > x) = pWait * exp(-rate x)
+	checkClose(t, "p99 queueing delay", sum.QueueDelayPercentiles[0].Value,
+		math.Log(100*pWait)/rate, 0.08)
+}
+
+func TestMD1(t *testing.T) {
+	t.Parallel()
+	lambda, serviceTime := 0.7, 1.0
+	gen := func() blocks.Generator {
+		return blocks.NewMDRandGenerator(lambda, serviceTime)
+	}
+	sum := singleQueue(t, gen, rtc, 1, 400000)
+
+	// Pollaczek-Khinchine with a deterministic service time
+	rho := lambda * serviceTime
+	wait := rho * serviceTime / (2 * (1 - rho))
+	checkClose(t, "mean latency", sum.Avg, wait+serviceTime, 0.05)
+	checkClose(t, "mean queueing delay", sum.QueueDelay, wait, 0.08)
+}
+
+func TestMG1PS(t *testing.T) {
+	t.Parallel()
+	// Lognormal service times with mean exp(1/2)
+	mean := math.Exp(0.5)
+	rho := 0.6
+	lambda := rho / mean
+	ps := func() blocks.Processor {
+		return blocks.NewPSProcessor()
+	}
+	gen := func() blocks.Generator {
+		return blocks.NewMLNGenerator(lambda, 0, 1)
+	}
+	sum := singleQueue(t, gen, ps, 1, 400000)
+
+	// The latency of processor sharing only depends on the mean service
+	// time, and every request is slowed down by 1/(1-rho) on average
+	checkClose(t, "mean latency", sum.Avg, mean/(1-rho), 0.05)
+	checkClose(t, "mean slowdown", sum.Slowdown, 1/(1-rho), 0.05)
+}
+
+func TestMM1TimeSharing(t *testing.T) {
+	t.Parallel()
+	lambda, mu := 0.7, 1.0
+	ts := func() blocks.Processor {
+		return blocks.NewTSProcessor(0.1)
+	}
+	gen := func() blocks.Generator {
+		return blocks.NewMMRandGenerator(lambda, mu)
+	}
+	sum := singleQueue(t, gen, ts, 1, 200000)
+
+	// With exponential service times round robin has the mean latency of
+	// first come first served
+	checkClose(t, "mean latency", sum.Avg, 1/(mu-lambda), 0.05)
+}
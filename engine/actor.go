@@ -1,36 +1,136 @@
 package engine
 
 import (
+	"iter"
 	"math/rand"
+	"runtime"
+)
+
+// Values sent by the model on the actor wake up channel
+const (
+	wakeUp = iota
+	stop
 )
 
 // Actor is the basic simulation element. Every element (generator or processor)
 // should have an actor as a nested struct.
 type Actor struct {
-	toModel   chan interface{}
+	sim       *Simulation
 	wakeUpCh  chan int
 	inQueues  []QueueInterface
 	outQueues []QueueInterface
+	rng       *rand.Rand
+	done      bool // Run returned, the actor left the simulation
+
+	// Coroutine execution
+	run   func()
+	yield func(interface{}) bool
+	next  func() (interface{}, bool)
+	stop  func()
 }
 
-func (a *Actor) init(ch chan interface{}) {
-	a.toModel = ch
+// actorStopped unwinds the stack of a coroutine actor when the simulation
+// is over
+type actorStopped struct{}
+
+func (a *Actor) init(s *Simulation) {
+	a.sim = s
 	a.wakeUpCh = make(chan int)
+	a.rng = s.NewRand()
+	for _, q := range a.inQueues {
+		s.registerQueue(q)
+	}
+	for _, q := range a.outQueues {
+		s.registerQueue(q)
+	}
+	s.actors = append(s.actors, a)
+}
+
+// block hands the event to the model and blocks until the model wakes the
+// actor up. If the simulation is over the actor goroutine exits.
+func (a *Actor) block(e interface{}) {
+	if a.yield != nil {
+		if !a.yield(e) {
+			panic(actorStopped{})
+		}
+		return
+	}
+	a.sim.eventChan <- e
+	if <-a.wakeUpCh == stop {
+		runtime.Goexit()
+	}
+}
+
+// runGoroutine runs the actor Run function in its own goroutine, and
+// tells the model when it returns
+func (a *Actor) runGoroutine() {
+	a.run()
+	a.done = true
+	a.sim.eventChan <- nil
+}
+
+// startCoroutine turns the actor Run function into a coroutine. Every event
+// the actor blocks on is yielded to the model, which resumes the actor by
+// pulling the next event.
+func (a *Actor) startCoroutine() {
+	a.next, a.stop = iter.Pull(func(yield func(interface{}) bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(actorStopped); !ok {
+					panic(r)
+				}
+			}
+		}()
+		a.yield = yield
+		a.run()
+	})
+}
+
+// GetTime returns the current time of the simulation the actor belongs to
+func (a *Actor) GetTime() float64 {
+	return a.sim.GetTime()
+}
+
+// WarmupEnd returns the time the warm-up of the simulation the actor belongs
+// to ended, or false if it is not over yet
+func (a *Actor) WarmupEnd() (float64, bool) {
+	return a.sim.WarmupEnd()
+}
+
+// Rand returns the actor's random stream. Every actor gets its own stream
+// derived from the simulation seed when it is registered.
+func (a *Actor) Rand() *rand.Rand {
+	return a.rng
 }
 
 // AddInQueue adds another input queue.
 // Input queues should be added in decreasing priority
 func (a *Actor) AddInQueue(q QueueInterface) {
+	if a.sim != nil {
+		a.sim.registerQueue(q)
+	}
 	a.inQueues = append(a.inQueues, q)
 }
 
 // AddOutQueue adds another output queue.
 // Output queues should be added in decreasing priority
 func (a *Actor) AddOutQueue(q QueueInterface) {
-	mdl.queues[q] = true
+	if a.sim != nil {
+		a.sim.registerQueue(q)
+	}
 	a.outQueues = append(a.outQueues, q)
 }
 
+// GetInQueue returns a given (idx) input queue
+func (a *Actor) GetInQueue(idx int) QueueInterface {
+	return a.inQueues[idx]
+}
+
+// GetOutQueue returns a given (idx) output queue
+func (a *Actor) GetOutQueue(idx int) QueueInterface {
+	return a.outQueues[idx]
+}
+
 // GetInQueueLen returns the length of a given (idx) input queue
 func (a *Actor) GetInQueueLen(idx int) int {
 	return a.inQueues[idx].Len()
@@ -71,9 +171,8 @@ func (a *Actor) GetInQueueCount() int {
 
 // Wait blocks the actor for a specific duration d
 func (a *Actor) Wait(d float64) {
-	e := timerEvent{time: d + mdl.getTime(), wakeUpCh: a.wakeUpCh}
-	a.toModel <- e
-	<-a.wakeUpCh // block
+	e := timerEvent{time: d + a.sim.GetTime(), actor: a}
+	a.block(e)
 }
 
 // WaitInterruptible blocks the actor for a d interval, unless there is an
@@ -89,18 +188,17 @@ func (a *Actor) WaitInterruptible(d float64) (bool, ReqInterface) {
 	if d < 0 {
 		return false, a.ReadInQueue()
 	}
-	timeoutTime := d + mdl.getTime()
+	timeoutTime := d + a.sim.GetTime()
 	lEvent := linkedEvent{
-		timerEvent: timerEvent{time: timeoutTime, wakeUpCh: a.wakeUpCh},
-		blockEvent: blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues},
+		timerEvent: timerEvent{time: timeoutTime, actor: a},
+		blockEvent: blockEvent{actor: a, queues: a.inQueues},
 	}
-	a.toModel <- lEvent
-	<-a.wakeUpCh
+	a.block(lEvent)
 
 	if a.inQueues[0].Len() > 0 {
 		return false, a.inQueues[0].Dequeue()
 	}
-	if mdl.getTime() == timeoutTime {
+	if a.sim.GetTime() == timeoutTime {
 		return true, nil
 	}
 
@@ -114,9 +212,8 @@ func (a *Actor) ReadInQueue() ReqInterface {
 		return a.inQueues[0].Dequeue()
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueue()
 }
 
@@ -125,9 +222,8 @@ func (a *Actor) ReadInQueueI(idx int) ReqInterface {
 		return a.inQueues[idx].Dequeue()
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueueI(idx)
 }
 
@@ -142,9 +238,8 @@ func (a *Actor) ReadInQueues() (ReqInterface, int) {
 		}
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 
 	return a.ReadInQueues()
 }
@@ -164,13 +259,12 @@ func (a *Actor) ReadInQueuesRand() (ReqInterface, int) {
 		}
 	}
 	if len(available) > 0 {
-		q := available[rand.Intn(len(available))]
+		q := available[a.rng.Intn(len(available))]
 		return q.q.Dequeue(), q.idx
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueues()
 }
 
@@ -188,16 +282,52 @@ func (a *Actor) ReadInQueuesRandLocalPr() (ReqInterface, int) {
 		}
 	}
 	if len(available) > 0 {
-		q := available[rand.Intn(len(available))]
+		q := available[a.rng.Intn(len(available))]
 		return q.q.Dequeue(), q.idx
 	}
 
-	bEvent := blockEvent{wakeUpCh: a.wakeUpCh, queues: a.inQueues}
-	a.toModel <- bEvent
-	<-a.wakeUpCh
+	bEvent := blockEvent{actor: a, queues: a.inQueues}
+	a.block(bEvent)
 	return a.ReadInQueuesRandLocalPr()
 }
 
+// WaitInQueue blocks till the given input queue is not empty, without
+// reading it. Unlike the Read functions it only blocks on that queue.
+func (a *Actor) WaitInQueue(idx int) {
+	for a.inQueues[idx].Len() == 0 {
+		bEvent := blockEvent{actor: a, queues: a.inQueues[idx : idx+1]}
+		a.block(bEvent)
+	}
+}
+
+// WaitInQueues blocks till any input queue is not empty, without reading it
+func (a *Actor) WaitInQueues() {
+	for {
+		for _, q := range a.inQueues {
+			if q.Len() > 0 {
+				return
+			}
+		}
+		bEvent := blockEvent{actor: a, queues: a.inQueues}
+		a.block(bEvent)
+	}
+}
+
+// WaitInQueuesIf blocks till an input queue q is not empty and wake(q) is
+// true, without reading it. The model checks wake whenever q is not empty,
+// and wakes up the other actors blocked on q if it is false.
+func (a *Actor) WaitInQueuesIf(wake func(q QueueInterface) bool) {
+	for {
+		for _, q := range a.inQueues {
+			if q.Len() > 0 && wake(q) {
+				return
+			}
+		}
+		bEvent := blockEvent{actor: a, queues: a.inQueues, wake: wake}
+		a.block(bEvent)
+	}
+}
+
 // WriteOutQueue writes a ReqInterface to the first output queue
 func (a *Actor) WriteOutQueue(el ReqInterface) {
 	a.outQueues[0].Enqueue(el)
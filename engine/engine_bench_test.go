@@ -0,0 +1,45 @@
+package engine_test
+
+import (
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// benchmarkEngine runs an M/M/4 single queue at 80% load and reports how
+// many actor wake ups the engine processes per second
+func benchmarkEngine(b *testing.B, mode engine.ExecMode) {
+	var events uint64
+	for i := 0; i < b.N; i++ {
+		sim := engine.InitSim(1)
+		sim.SetExecMode(mode)
+
+		stats := &blocks.AllKeeper{}
+		sim.InitStats(stats)
+
+		q := blocks.NewQueue()
+		for j := 0; j < 4; j++ {
+			p := &blocks.RTCProcessor{}
+			p.AddInQueue(q)
+			p.SetReqDrain(stats)
+			sim.RegisterActor(p)
+		}
+		g := blocks.NewMMRandGenerator(0.064, 0.02)
+		g.SetCreator(blocks.NewSimpleReqCreator(sim))
+		g.AddOutQueue(q)
+		sim.RegisterActor(g)
+
+		sim.Run(1000000)
+		events += sim.EventCount()
+	}
+	b.ReportMetric(float64(events)/b.Elapsed().Seconds(), "events/s")
+}
+
+func BenchmarkGoroutines(b *testing.B) {
+	benchmarkEngine(b, engine.Goroutines)
+}
+
+func BenchmarkCoroutines(b *testing.B) {
+	benchmarkEngine(b, engine.Coroutines)
+}
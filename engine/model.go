@@ -3,10 +3,11 @@ package engine
 import (
 	"container/heap"
 	"container/list"
+	"io"
+	"math"
+	"math/rand"
 )
 
-var mdl *model
-
 // ActorInterface is the main interface to be used in main package.
 // Every element of the topology should implement this interface.
 // Init, AddInQueuem AddOutQueue are provided by the Actor nested struct and
@@ -15,7 +16,7 @@ type ActorInterface interface {
 	Run()
 	AddInQueue(q QueueInterface)
 	AddOutQueue(q QueueInterface)
-	init(ch chan interface{})
+	init(s *Simulation)
 }
 
 // ReqInterface describes what a basic request should look like
@@ -32,41 +33,61 @@ type QueueInterface interface {
 	Len() int
 }
 
+// simulationBinder is implemented by the queues that need the simulation,
+// e.g. for its time. SetSimulation is called when the queue is registered.
+type simulationBinder interface {
+	SetSimulation(s *Simulation)
+}
+
+// StopCondition lets a simulation stop before its time threshold.
+// Done is checked after every event.
+type StopCondition interface {
+	Done() bool
+}
+
 // Stats is an interface that is called at the end of the simulation and
-// prints the collected statistics
+// prints the collected statistics to w
 type Stats interface {
-	PrintStats()
+	PrintStats(w io.Writer)
+	SetSimulation(s *Simulation)
 }
 
 type timerEventInterface interface {
 	getTime() float64
+	getSeq() uint64
 	setIdx(idx int)
-	getChannel() chan int
+	getActor() *Actor
 }
 
 type timerEvent struct {
-	time     float64
-	wakeUpCh chan int
-	idx      int
+	time  float64
+	seq   uint64
+	actor *Actor
+	idx   int
 }
 
 func (te *timerEvent) getTime() float64 {
 	return te.time
 }
 
+func (te *timerEvent) getSeq() uint64 {
+	return te.seq
+}
+
 func (te *timerEvent) setIdx(idx int) {
 	te.idx = idx
 }
 
-func (te *timerEvent) getChannel() chan int {
-	return te.wakeUpCh
+func (te *timerEvent) getActor() *Actor {
+	return te.actor
 }
 
 type blockEventInterface interface {
-	getChannel() chan int
+	getActor() *Actor
 	getQueues() []QueueInterface
 	deactivateReplicas()
 	addReplica(pair listElPair)
+	wakes(q QueueInterface) bool
 }
 
 type listElPair struct {
@@ -75,19 +96,25 @@ type listElPair struct {
 }
 
 type blockEvent struct {
-	wakeUpCh chan int
+	actor    *Actor
 	queues   []QueueInterface
 	replicas []listElPair
+	wake     func(q QueueInterface) bool // nil wakes on any non-empty queue
 }
 
-func (be *blockEvent) getChannel() chan int {
-	return be.wakeUpCh
+func (be *blockEvent) getActor() *Actor {
+	return be.actor
 }
 
 func (be *blockEvent) getQueues() []QueueInterface {
 	return be.queues
 }
 
+// wakes returns true if the actor should be woken up by q not being empty
+func (be *blockEvent) wakes(q QueueInterface) bool {
+	return be.wake == nil || be.wake(q)
+}
+
 func (be *blockEvent) deactivateReplicas() {
 	for _, pair := range be.replicas {
 		pair.l.Remove(pair.el)
@@ -103,38 +130,125 @@ type linkedEvent struct {
 	blockEvent
 }
 
-func (le *linkedEvent) getChannel() chan int {
-	return le.blockEvent.wakeUpCh
+func (le *linkedEvent) getActor() *Actor {
+	return le.blockEvent.actor
+}
+
+// warmup tracks the warm-up period, which ends at a given time or after a
+// given number of completed requests
+type warmup struct {
+	time      float64
+	count     int
+	completed int
+	countEnd  float64 // when the count was reached
+	end       float64
+	over      bool
 }
 
-type model struct {
+// ExecMode selects how the actors of a simulation are executed
+type ExecMode int
+
+const (
+	// Goroutines runs every actor in its own goroutine, synchronised with
+	// the model over channels
+	Goroutines ExecMode = iota
+	// Coroutines runs every actor as a coroutine resumed directly by the
+	// model loop, without any cross-goroutine synchronisation
+	Coroutines
+)
+
+// Simulation is a single, self-contained simulation. Actors, queues, stats
+// and request creators are bound to the Simulation they are part of, so
+// independent simulations can run concurrently in the same process.
+//
+// The simulation is deterministic for a given seed and topology:
+//   - actors start one at a time in registration order
+//   - before every timer event, the actors blocked on non-empty queues are
+//     woken up queue by queue in queue registration order, and for every
+//     queue in the order they blocked on it, as long as it is non-empty.
+//     Actors that block on a queue again, or on a queue already visited,
+//     wait for the next timer event
+//   - timer events with the same time fire in the order they were added
+//
+// An actor whose Run function returns leaves the simulation.
+type Simulation struct {
 	time            float64
-	actorCount      int
+	actors          []*Actor
+	elements        []ActorInterface // the registered actors
 	pq              priorityQueue
 	eventChan       chan interface{}
 	blockedInQueues map[QueueInterface]*list.List
-	queues          map[QueueInterface]bool
+	queues          []QueueInterface
+	queueSet        map[QueueInterface]bool
 	bookkeeping     []Stats
+	stopConditions  []StopCondition
+	warmup          warmup
+	rng             *rand.Rand
+	mode            ExecMode
+	events          uint64
+	seq             uint64
+	blocked         []blockEventInterface // scratch list of the Run loop
 }
 
-func newModel() *model {
-	m := &model{}
+// InitSim initialises a new simulation. All the randomness of the simulation
+// is derived from the given seed.
+func InitSim(seed int64) *Simulation {
+	m := &Simulation{}
+	m.rng = rand.New(rand.NewSource(seed))
 	m.eventChan = make(chan interface{})
 	m.pq = make(priorityQueue, 0)
-	m.queues = make(map[QueueInterface]bool)
+	m.queueSet = make(map[QueueInterface]bool)
 	m.blockedInQueues = make(map[QueueInterface]*list.List)
 	heap.Init(&m.pq)
 	return m
 }
 
-func (m *model) registerActor(a ActorInterface) {
-	a.init(m.eventChan)
-	m.actorCount++
+// NewRand returns a new random stream derived from the simulation seed.
+// Streams are handed out in call order, so the same seed and topology
+// always produce the same streams. It should be used by elements that are
+// not actors but need randomness.
+func (m *Simulation) NewRand() *rand.Rand {
+	return rand.New(rand.NewSource(m.rng.Int63()))
+}
+
+// SetExecMode selects how actors are executed. It should be called before
+// Run. The default is Goroutines.
+func (m *Simulation) SetExecMode(mode ExecMode) {
+	m.mode = mode
+}
 
-	go a.Run()
+// RegisterActor registers a specific simulation element.
+// All actors should be registered after their queues are added.
+// Actors are started by Run in registration order.
+func (m *Simulation) RegisterActor(a ActorInterface) {
+	a.init(m)
+	m.actors[len(m.actors)-1].run = a.Run
+	m.elements = append(m.elements, a)
 }
 
-func (m *model) registerBlockEvent(e blockEventInterface) {
+// Actors returns the registered actors in registration order
+func (m *Simulation) Actors() []ActorInterface {
+	return m.elements
+}
+
+// Queues returns the queues of the registered actors in registration order
+func (m *Simulation) Queues() []QueueInterface {
+	return m.queues
+}
+
+// registerQueue adds a queue to the queues the model watches. Queues are
+// kept in registration order.
+func (m *Simulation) registerQueue(q QueueInterface) {
+	if !m.queueSet[q] {
+		m.queueSet[q] = true
+		m.queues = append(m.queues, q)
+		if b, ok := q.(simulationBinder); ok {
+			b.SetSimulation(m)
+		}
+	}
+}
+
+func (m *Simulation) registerBlockEvent(e blockEventInterface) {
 	for _, q := range e.getQueues() {
 		if _, ok := m.blockedInQueues[q]; !ok {
 			m.blockedInQueues[q] = list.New()
@@ -144,13 +258,14 @@ func (m *model) registerBlockEvent(e blockEventInterface) {
 	}
 }
 
-func (m *model) getTime() float64 {
+// GetTime returns the current simulation time
+func (m *Simulation) GetTime() float64 {
 	return m.time
 }
 
-func (m *model) waitActor() {
-	newEvent := <-m.eventChan
+func (m *Simulation) handleEvent(newEvent interface{}) {
 	if timerE, ok := newEvent.(timerEvent); ok {
+		timerE.seq = m.nextSeq()
 		heap.Push(&m.pq, &timerE)
 		return
 	}
@@ -159,22 +274,134 @@ func (m *model) waitActor() {
 		return
 	}
 	if linkedE, ok := newEvent.(linkedEvent); ok {
+		linkedE.seq = m.nextSeq()
 		heap.Push(&m.pq, &linkedE)
 		m.registerBlockEvent(&linkedE)
 		return
 	}
 }
 
-func (m *model) run(threshold float64) {
-	////wait for all actors to start and add an event or block on a queue
-	for i := 0; i < m.actorCount; i++ {
-		m.waitActor()
+func (m *Simulation) nextSeq() uint64 {
+	m.seq++
+	return m.seq
+}
+
+// waitActor waits till the running actor adds an event or blocks in a queue
+func (m *Simulation) waitActor() {
+	m.handleEvent(<-m.eventChan)
+}
+
+// resume wakes up a blocked actor and waits till it blocks again
+func (m *Simulation) resume(a *Actor) {
+	m.events++
+	if m.mode == Coroutines {
+		e, ok := a.next()
+		if !ok {
+			a.done = true
+			return
+		}
+		m.handleEvent(e)
+		return
+	}
+	a.wakeUpCh <- wakeUp
+	m.waitActor()
+}
+
+// startActors starts the actors one at a time in registration order. Each
+// actor runs till it adds an event or blocks on a queue before the next one
+// starts, so the start up is deterministic.
+func (m *Simulation) startActors() {
+	for _, a := range m.actors {
+		if m.mode == Coroutines {
+			a.startCoroutine()
+			m.resume(a)
+		} else {
+			m.events++
+			go a.runGoroutine()
+			m.waitActor()
+		}
 	}
+}
+
+// SetWarmupTime sets a warm-up period of the given duration. Statistics
+// discard the requests born during the warm-up.
+func (m *Simulation) SetWarmupTime(t float64) {
+	m.warmup.time = t
+	m.warmup.over = false
+}
+
+// SetWarmupCount sets a warm-up period that lasts till the given number of
+// requests completed. Statistics discard the requests born during the
+// warm-up.
+func (m *Simulation) SetWarmupCount(n int) {
+	m.warmup.count = n
+	m.warmup.over = false
+}
+
+// NotifyCompletion should be called by the request drains for every
+// completed request, measured or not, to track the warm-up.
+func (m *Simulation) NotifyCompletion() {
+	m.warmup.completed++
+	if m.warmup.completed == m.warmup.count {
+		m.warmup.countEnd = m.time
+	}
+}
+
+// Completions returns the number of completed requests, including the
+// warm-up
+func (m *Simulation) Completions() int {
+	return m.warmup.completed
+}
+
+// WarmupEnd returns the time the warm-up period ended, or false if it is
+// not over yet
+func (m *Simulation) WarmupEnd() (float64, bool) {
+	w := &m.warmup
+	if !w.over && m.time >= w.time && w.completed >= w.count {
+		w.over = true
+		w.end = math.Max(w.time, w.countEnd)
+	}
+	return w.end, w.over
+}
+
+// MeasurementTime returns how long statistics have been collected, that is
+// the time since the end of the warm-up
+func (m *Simulation) MeasurementTime() float64 {
+	end, over := m.WarmupEnd()
+	if !over {
+		return 0
+	}
+	return m.time - end
+}
+
+// AddStopCondition adds a condition for stopping the simulation early.
+// The simulation stops when all the conditions are met, or at the
+// threshold time given to Run, whichever comes first.
+func (m *Simulation) AddStopCondition(c StopCondition) {
+	m.stopConditions = append(m.stopConditions, c)
+}
+
+func (m *Simulation) stopConditionsMet() bool {
+	if len(m.stopConditions) == 0 {
+		return false
+	}
+	for _, c := range m.stopConditions {
+		if !c.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs the simulation till the given threshold time or till the stop
+// conditions are met
+func (m *Simulation) Run(threshold float64) {
+	m.startActors()
 
 	//all actors started
-	for m.time < threshold {
+	for m.time < threshold && !m.stopConditionsMet() {
 
-		for q := range m.queues {
+		for _, q := range m.queues {
 			if q.Len() == 0 {
 				continue
 			}
@@ -188,17 +415,26 @@ func (m *model) run(threshold float64) {
 				continue
 			}
 
-			for e := m.blockedInQueues[q].Front(); e != nil && q.Len() > 0; e = e.Next() {
-				be := e.Value.(blockEventInterface)
+			// Waking an actor removes it from the list and the actor can
+			// block on q again, so walk the actors blocked so far
+			m.blocked = m.blocked[:0]
+			for e := m.blockedInQueues[q].Front(); e != nil; e = e.Next() {
+				m.blocked = append(m.blocked, e.Value.(blockEventInterface))
+			}
+			for _, be := range m.blocked {
+				if q.Len() == 0 {
+					break
+				}
+				if !be.wakes(q) {
+					continue
+				}
 				// Remove the blockEvents for the rest of the queues if any
 				be.deactivateReplicas()
 
-				if linkedE, ok := e.Value.(*linkedEvent); ok {
+				if linkedE, ok := be.(*linkedEvent); ok {
 					heap.Remove(&m.pq, linkedE.timerEvent.idx)
 				}
-				be.getChannel() <- 1 // try to unblock
-				m.waitActor()
-				//m.blockedInQueues[q].Remove(e)
+				m.resume(be.getActor()) // try to unblock
 			}
 		}
 
@@ -210,40 +446,47 @@ func (m *model) run(threshold float64) {
 		if linkedE, ok := e.(*linkedEvent); ok {
 			linkedE.blockEvent.deactivateReplicas()
 		}
-		e.getChannel() <- 1
-
-		// wait till process adds event or blocks in queue
-		m.waitActor()
-	}
-	for _, s := range m.bookkeeping {
-		s.PrintStats()
+		m.resume(e.getActor())
 	}
+	m.stopActors()
 }
 
-// InitSim initialises the simulation
-func InitSim() {
-	mdl = newModel()
+// EventCount returns how many times an actor was woken up so far
+func (m *Simulation) EventCount() uint64 {
+	return m.events
 }
 
-// GetTime returns the current simulation time
-func GetTime() float64 {
-	return mdl.getTime()
+// stopActors terminates the goroutines of all the actors. Every actor still
+// running is blocked waiting for the model at this point.
+func (m *Simulation) stopActors() {
+	for _, a := range m.actors {
+		if a.done {
+			continue
+		}
+		if m.mode == Coroutines {
+			a.stop()
+		} else {
+			a.wakeUpCh <- stop
+		}
+	}
 }
 
-// RegisterActor registers a specific simulation element.
-// All actors should be registered
-func RegisterActor(a ActorInterface) {
-	mdl.registerActor(a)
+// InitStats sets the interface in charge of collecting statistics.
+// This is interface is called at the end of the simulation to print the
+// collected statistics
+func (m *Simulation) InitStats(s Stats) {
+	s.SetSimulation(m)
+	m.bookkeeping = append(m.bookkeeping, s)
 }
 
-// Run runs the simulation for till the given threshold time
-func Run(threshold float64) {
-	mdl.run(threshold)
+// GetStats returns the statistics collectors in registration order
+func (m *Simulation) GetStats() []Stats {
+	return m.bookkeeping
 }
 
-// InitStats sets the interface in charge of collecting statistics.
-// This is interface is called at the end of the simulation to print the
-// collected statistics
-func InitStats(s Stats) {
-	mdl.bookkeeping = append(mdl.bookkeeping, s)
+// PrintStats prints the statistics of all the collectors to w
+func (m *Simulation) PrintStats(w io.Writer) {
+	for _, s := range m.bookkeeping {
+		s.PrintStats(w)
+	}
 }
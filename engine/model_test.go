@@ -0,0 +1,116 @@
+package engine_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// ticker waits interval between ticks, and leaves the simulation after
+// count ticks if count is positive
+type ticker struct {
+	engine.Actor
+	interval float64
+	count    int
+	ticks    []float64
+}
+
+func (t *ticker) Run() {
+	for t.count <= 0 || len(t.ticks) < t.count {
+		t.ticks = append(t.ticks, t.GetTime())
+		t.Wait(t.interval)
+	}
+}
+
+func TestActorLeavesSimulation(t *testing.T) {
+	for _, mode := range []engine.ExecMode{engine.Goroutines, engine.Coroutines} {
+		sim := engine.InitSim(1)
+		sim.SetExecMode(mode)
+
+		leaving := &ticker{interval: 10, count: 3}
+		staying := &ticker{interval: 25}
+		sim.RegisterActor(leaving)
+		sim.RegisterActor(staying)
+		sim.Run(100)
+
+		if got, want := leaving.ticks, []float64{0, 10, 20}; !slices.Equal(got, want) {
+			t.Errorf("mode %v: leaving actor ticked at %v, want %v", mode, got, want)
+		}
+		if got, want := staying.ticks, []float64{0, 25, 50, 75, 100}; !slices.Equal(got, want) {
+			t.Errorf("mode %v: staying actor ticked at %v, want %v", mode, got, want)
+		}
+	}
+}
+
+type req struct{}
+
+func (req) GetDelay() float64        { return 0 }
+func (req) GetServiceTime() float64  { return 0 }
+func (req) SubServiceTime(t float64) {}
+
+type fifo struct {
+	reqs []engine.ReqInterface
+}
+
+func (q *fifo) Enqueue(r engine.ReqInterface) { q.reqs = append(q.reqs, r) }
+func (q *fifo) Len() int                      { return len(q.reqs) }
+func (q *fifo) Dequeue() engine.ReqInterface {
+	r := q.reqs[0]
+	q.reqs = q.reqs[1:]
+	return r
+}
+
+// burst writes count requests at once, then waits till the end
+type burst struct {
+	engine.Actor
+	count int
+}
+
+func (b *burst) Run() {
+	for i := 0; i < b.count; i++ {
+		b.WriteOutQueue(req{})
+	}
+	b.Wait(1000)
+}
+
+// reader serves every request it reads for a fixed time
+type reader struct {
+	engine.Actor
+	id    int
+	reads *[]string
+}
+
+func (r *reader) Run() {
+	for {
+		r.ReadInQueue()
+		*r.reads = append(*r.reads, fmt.Sprintf("%v@%v", r.id, r.GetTime()))
+		r.Wait(10)
+	}
+}
+
+// Every actor blocked on a queue is woken up while the queue holds requests,
+// in the order they blocked, without waiting for another timer event
+func TestBlockedActorsWakeInOrder(t *testing.T) {
+	for _, mode := range []engine.ExecMode{engine.Goroutines, engine.Coroutines} {
+		sim := engine.InitSim(1)
+		sim.SetExecMode(mode)
+
+		var reads []string
+		q := &fifo{}
+		for i := 0; i < 4; i++ {
+			r := &reader{id: i, reads: &reads}
+			r.AddInQueue(q)
+			sim.RegisterActor(r)
+		}
+		b := &burst{count: 3}
+		b.AddOutQueue(q)
+		sim.RegisterActor(b)
+		sim.Run(100)
+
+		if want := []string{"0@0", "1@0", "2@0"}; !slices.Equal(reads, want) {
+			t.Errorf("mode %v: reads %v, want %v", mode, reads, want)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package engine
 
 // timerEvent pointer because we change the index
+// Events with the same time are ordered by their sequence number, so they
+// fire in the order they were pushed (FIFO)
 type priorityQueue []timerEventInterface
 
 func (pq priorityQueue) Len() int { return len(pq) }
 
 func (pq priorityQueue) Less(i, j int) bool {
+	if pq[i].getTime() == pq[j].getTime() {
+		return pq[i].getSeq() < pq[j].getSeq()
+	}
 	return pq[i].getTime() < pq[j].getTime() // greater time - less priority
 }
 
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// list prints all the registered components and their parameters
+func list() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	kind := ""
+	for _, c := range blocks.Components() {
+		if c.Kind != kind {
+			if kind != "" {
+				fmt.Fprintln(w)
+			}
+			kind = c.Kind
+			fmt.Fprintf(w, "%vs:\n", kind)
+		}
+		fmt.Fprintf(w, "  %v\t%v\n", c.Name, c.Doc)
+		for _, p := range c.Params {
+			def := "required"
+			if p.Default != nil {
+				def = fmt.Sprintf("default %v", p.Default)
+			}
+			fmt.Fprintf(w, "    %v\t%v, %v: %v\n", p.Name, p.Kind, def, p.Doc)
+		}
+	}
+	w.Flush()
+}
@@ -3,29 +3,370 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
 
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
 	"github.com/epfl-dcsl/schedsim/topologies"
 )
 
+// simFlags holds the flags shared by all the commands that run simulations
+type simFlags struct {
+	fs         *flag.FlagSet
+	params     topologies.Params
+	genParams  string
+	procParams string
+	config     string
+	execMode   string
+	duration   float64
+	seed       int64
+
+	replications int
+	percentiles  string
+	classes      string
+
+	warmup      float64
+	warmupCount int
+
+	// Stop conditions
+	stopCount    int
+	stopPerDrain int
+	ciMetric     string
+	ciWidth      float64
+}
+
+func addSimFlags(fs *flag.FlagSet) *simFlags {
+	f := &simFlags{fs: fs}
+	fs.IntVar(&f.params.Topo, "topo", 0, "topology selector")
+	fs.IntVar(&f.params.Cores, "cores", 1, "number of cores, overrides the cores of a topology file")
+	fs.IntVar(&f.params.Cores, "num_cores", 1, "same as --cores")
+	fs.StringVar(&f.config, "config", "", "JSON topology description, overrides --topo")
+	fs.Float64Var(&f.params.Mu, "mu", 0.02, "mu service rate") // default 50usec
+	fs.IntVar(&f.params.GenType, "genType", 0, "type of generator")
+	fs.IntVar(&f.params.ProcType, "procType", 0, "type of processor")
+	fs.StringVar(&f.params.Generator, "gen", "", "generator by name, overrides --genType (see schedsim list)")
+	fs.StringVar(&f.genParams, "genParams", "", "generator parameters as name=value,...")
+	fs.StringVar(&f.params.Dispatch, "dispatch", "", "dispatch policy of the generator, or of the dispatcher of topology 3, like jsq or pod(d=2) (see schedsim list)")
+	fs.Float64Var(&f.params.DispatchCost, "dispatchCost", 0, "time of every decision of the dispatcher of topology 3, 0 for an ideal dispatcher")
+	fs.StringVar(&f.params.Processor, "proc", "", "processor by name, overrides --procType (see schedsim list)")
+	fs.StringVar(&f.procParams, "procParams", "", "processor parameters as name=value,...")
+	fs.StringVar(&f.params.Drain, "drain", "all", "drain collecting the statistics of the predefined topologies (see schedsim list)")
+	fs.IntVar(&f.params.BufferSize, "buffersize", 1, "size of the bounded buffer")
+	fs.Float64Var(&f.duration, "duration", 10000000, "experiment duration")
+	fs.StringVar(&f.execMode, "engine", "goroutines", "actor execution: goroutines or coroutines")
+	fs.Int64Var(&f.seed, "seed", 0, "random seed, 0 seeds with the current time")
+	fs.IntVar(&f.replications, "replications", 1, "number of independently seeded runs, reports the mean and 95% CI of each metric")
+	fs.StringVar(&f.percentiles, "percentiles", "", "reported percentiles of all collectors, like p50,p99.9,max")
+	fs.StringVar(&f.classes, "classes", "", "service time class boundaries of all AllKeepers, like 10,100")
+	fs.Float64Var(&f.warmup, "warmup", 0, "warm-up time, requests born before it are not measured")
+	fs.IntVar(&f.warmupCount, "warmupCount", 0, "warm-up till this many requests completed, requests born before are not measured")
+	fs.IntVar(&f.stopCount, "stopCount", 0, "stop after this many completed requests in total")
+	fs.IntVar(&f.stopPerDrain, "stopPerDrain", 0, "stop after this many completed requests in every drain")
+	fs.StringVar(&f.ciMetric, "ciMetric", "mean", "metric for --ciWidth: mean or a percentile like p99")
+	fs.Float64Var(&f.ciWidth, "ciWidth", 0, "stop when the 95% CI half width of --ciMetric is below this fraction of it")
+	return f
+}
+
+func (f *simFlags) getSeed() int64 {
+	if f.seed == 0 {
+		f.seed = time.Now().UTC().UnixNano()
+	}
+	return f.seed
+}
+
+// loadConfig loads the topology file, with the number of cores of the
+// flags if given
+func (f *simFlags) loadConfig() (*topologies.Config, error) {
+	c, err := topologies.LoadConfig(f.config)
+	if err != nil {
+		return nil, err
+	}
+	if isFlagSet(f.fs, "cores") || isFlagSet(f.fs, "num_cores") {
+		c.SetCores(f.params.Cores)
+	}
+	return c, nil
+}
+
+// cores returns the number of cores of the selected topology. For topology
+// files it is the number of processors.
+func (f *simFlags) cores() (int, error) {
+	if f.config == "" {
+		return f.params.Cores, nil
+	}
+	c, err := f.loadConfig()
+	if err != nil {
+		return 0, err
+	}
+	_, _, count := c.Components()
+	return count, nil
+}
+
+// setCollectors selects the percentiles and the service time classes
+// reported by all the collectors of sim, if given
+func (f *simFlags) setCollectors(sim *engine.Simulation) error {
+	var quantiles, classes []float64
+	var err error
+	if f.percentiles != "" {
+		if quantiles, err = blocks.ParsePercentiles(strings.Split(f.percentiles, ",")); err != nil {
+			return err
+		}
+	}
+	if f.classes != "" {
+		if classes, err = blocks.ParseFloats(strings.Split(f.classes, ",")); err != nil {
+			return fmt.Errorf("bad service time classes: %v", err)
+		}
+	}
+	for _, s := range sim.GetStats() {
+		if ps, ok := s.(blocks.PercentileSetter); ok && quantiles != nil {
+			ps.SetPercentiles(quantiles)
+		}
+		if _, ok := s.(blocks.RequestDrain); !ok || classes == nil {
+			continue
+		}
+		cs, ok := s.(blocks.ServiceClassSetter)
+		if !ok {
+			return fmt.Errorf("service time classes need the all drain")
+		}
+		cs.SetServiceClasses(classes)
+	}
+	return nil
+}
+
+// addStopConditions adds the selected stop conditions on the drains of sim.
+// Conditions are combined, and --duration always stops the simulation.
+func (f *simFlags) addStopConditions(sim *engine.Simulation) error {
+	var drains []blocks.RequestDrain
+	var keepers []*blocks.AllKeeper
+	for _, s := range sim.GetStats() {
+		if d, ok := s.(blocks.RequestDrain); ok {
+			drains = append(drains, d)
+		}
+		if k, ok := s.(*blocks.AllKeeper); ok {
+			keepers = append(keepers, k)
+		}
+	}
+	if f.stopCount > 0 {
+		sim.AddStopCondition(blocks.NewCountStop(f.stopCount, drains...))
+	}
+	if f.stopPerDrain > 0 {
+		sim.AddStopCondition(blocks.NewPerDrainCountStop(f.stopPerDrain, drains...))
+	}
+	if f.ciWidth > 0 {
+		quantile := 0.0
+		if f.ciMetric != "mean" {
+			q, err := blocks.ParsePercentile(f.ciMetric)
+			if err != nil || q == 0 || q == 1 {
+				return fmt.Errorf("bad CI metric: %v", f.ciMetric)
+			}
+			quantile = q
+		}
+		// Batch means need the samples in arrival order
+		if len(keepers) == 0 {
+			return fmt.Errorf("--ciWidth needs an all drain, which keeps every sample")
+		}
+		for _, k := range keepers {
+			sim.AddStopCondition(blocks.NewCIStop(k, quantile, f.ciWidth))
+		}
+	}
+	return nil
+}
+
+// buildSim creates a new simulation with the selected topology and the given
+// arrival rate. For topology files a non-positive lambda keeps the arrival
+// rates of the file.
+func (f *simFlags) buildSim(lambda float64, seed int64) (*engine.Simulation, error) {
+	sim := engine.InitSim(seed)
+	switch f.execMode {
+	case "goroutines":
+		sim.SetExecMode(engine.Goroutines)
+	case "coroutines":
+		sim.SetExecMode(engine.Coroutines)
+	default:
+		return nil, fmt.Errorf("unknown engine: %v", f.execMode)
+	}
+	sim.SetWarmupTime(f.warmup)
+	sim.SetWarmupCount(f.warmupCount)
+	if f.config != "" {
+		c, err := f.loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		// Keep the arrival rates of the file if none is given
+		if lambda > 0 {
+			c.SetLambda(lambda)
+		}
+		if err := c.Build(sim); err != nil {
+			return nil, err
+		}
+		return sim, f.finishSim(sim)
+	}
+	p := f.params
+	p.Lambda = lambda
+	var err error
+	if p.GenParams, err = blocks.ParseParams(f.genParams); err != nil {
+		return nil, err
+	}
+	if p.ProcParams, err = blocks.ParseParams(f.procParams); err != nil {
+		return nil, err
+	}
+	if err := topologies.Build(sim, p); err != nil {
+		return nil, err
+	}
+	return sim, f.finishSim(sim)
+}
+
+// finishSim configures the collectors of a built simulation and adds the
+// processor utilisation, queue length and dispatcher reports
+func (f *simFlags) finishSim(sim *engine.Simulation) error {
+	sim.InitStats(blocks.NewUsageStats())
+	sim.InitStats(blocks.NewQueueLengthStats())
+	if blocks.HasDispatchers(sim) {
+		sim.InitStats(blocks.NewDispatcherStats())
+	}
+	if err := f.setCollectors(sim); err != nil {
+		return err
+	}
+	return f.addStopConditions(sim)
+}
+
+// runSampled builds and runs a simulation. If interval is positive a
+// sampler writes the state of the simulation to path at that interval.
+func (f *simFlags) runSampled(lambda float64, seed int64, interval float64, path string) (*engine.Simulation, error) {
+	sim, err := f.buildSim(lambda, seed)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		sim.Run(f.duration)
+		return sim, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// Registered last so sampling does not change the run
+	sampler := blocks.NewSampler(sim, interval, f.duration, file)
+	sim.RegisterActor(sampler)
+	sim.Run(f.duration)
+	return sim, sampler.Flush()
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "schedsim: %v\n", err)
+	os.Exit(2)
+}
+
 func main() {
-	var topo = flag.Int("topo", 0, "topology selector")
-	var mu = flag.Float64("mu", 0.02, "mu service rate") // default 50usec
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "sweep":
+			sweep(os.Args[2:])
+			return
+		case "list":
+			list()
+			return
+		case "analytic":
+			analyticCmd(os.Args[2:])
+			return
+		}
+	}
+
+	f := addSimFlags(flag.CommandLine)
 	var lambda = flag.Float64("lambda", 0.005, "lambda poisson interarrival")
-	var genType = flag.Int("genType", 0, "type of generator")
-	var procType = flag.Int("procType", 0, "type of processor")
-	var duration = flag.Float64("duration", 10000000, "experiment duration")
-	var bufferSize = flag.Int("buffersize", 1, "size of the bounded buffer")
+	var output = flag.String("output", "text", "output format: text, csv or json")
+	var out = flag.String("out", "", "output file, stdout if empty")
+	var sample = flag.Float64("sample", 0, "sample queue lengths, processor states and completions at this simulated-time interval")
+	var sampleOut = flag.String("sampleOut", "samples.csv", "file of the samples")
 
 	flag.Parse()
-	fmt.Printf("Selected topology: %v\n", *topo)
-
-	if *topo == 0 {
-		topologies.SingleQueue(*lambda, *mu, *duration, *genType, *procType)
-	} else if *topo == 1 {
-		topologies.MultiQueue(*lambda, *mu, *duration, *genType, *procType)
-	} else if *topo == 2 {
-		topologies.BoundedQueue(*lambda, *mu, *duration, *bufferSize)
+	seed := f.getSeed()
+	if f.replications > 1 && *sample > 0 {
+		fatal(fmt.Errorf("sampling needs a single run, not replications"))
+	}
+	var write func(io.Writer, runOutput) error
+	if *output != "text" {
+		var err error
+		if write, err = runWriter(*output); err != nil {
+			fatal(err)
+		}
+	}
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	// Only override the arrival rates of a topology file if asked to
+	if f.config != "" && !isFlagSet(flag.CommandLine, "lambda") {
+		*lambda = 0
+	}
+	if write != nil {
+		params, err := f.getRunParams(*lambda, seed)
+		if err != nil {
+			fatal(err)
+		}
+		res := runOutput{Params: params}
+		if f.replications > 1 {
+			stats, err := runReplications(f, *lambda, seed, f.replications, runtime.NumCPU())
+			if err != nil {
+				fatal(err)
+			}
+			res.Stats = replicatedRecords(stats)
+		} else {
+			sim, err := f.runSampled(*lambda, seed, *sample, *sampleOut)
+			if err != nil {
+				fatal(err)
+			}
+			res.Stats = getRecords(sim)
+		}
+		if err := write(w, res); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if f.config != "" {
+		fmt.Fprintf(w, "Selected topology: %v\n", f.config)
 	} else {
-		panic("Unknown topology")
+		fmt.Fprintf(w, "Selected topology: %v\n", f.params.Topo)
+	}
+	if *lambda > 0 {
+		cores, err := f.cores()
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(w, "Cores:%v\tservice_rate:%v\tinterarrival_rate:%v\tseed:%v\n", cores, f.params.Mu, *lambda, seed)
+	}
+	if f.replications > 1 {
+		stats, err := runReplications(f, *lambda, seed, f.replications, runtime.NumCPU())
+		if err != nil {
+			fatal(err)
+		}
+		printReplications(w, stats)
+		return
+	}
+	sim, err := f.runSampled(*lambda, seed, *sample, *sampleOut)
+	if err != nil {
+		fatal(err)
 	}
+	sim.PrintStats(w)
 }
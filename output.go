@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// runParams are the parameters of a run reported along with its statistics.
+// Lambda is zero when a topology file keeps its own arrival rates.
+type runParams struct {
+	Topology  string  `json:"topology"`
+	Lambda    float64 `json:"lambda"`
+	Mu        float64 `json:"mu"`
+	Generator string  `json:"generator"`
+	Processor string  `json:"processor"`
+	Cores     int     `json:"cores"`
+	Seed      int64   `json:"seed"`
+}
+
+// runOutput is the structured output of a run
+type runOutput struct {
+	Params runParams       `json:"params"`
+	Stats  []blocks.Record `json:"stats"`
+}
+
+// getRunParams describes the run selected by the flags. For topology files
+// generators and processors list the types used and cores is the number of
+// processors.
+func (f *simFlags) getRunParams(lambda float64, seed int64) (runParams, error) {
+	res := runParams{Lambda: lambda, Mu: f.params.Mu, Seed: seed}
+	if f.config != "" {
+		c, err := f.loadConfig()
+		if err != nil {
+			return res, err
+		}
+		gens, procs, count := c.Components()
+		res.Topology = f.config
+		res.Generator = strings.Join(gens, ",")
+		res.Processor = strings.Join(procs, ",")
+		res.Cores = count
+		return res, nil
+	}
+	var err error
+	res.Topology = strconv.Itoa(f.params.Topo)
+	res.Cores = f.params.Cores
+	if res.Generator, err = f.params.GeneratorName(); err != nil {
+		return res, err
+	}
+	if res.Processor, err = f.params.ProcessorName(); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// getRecords returns the records of all the statistics of sim that have one
+func getRecords(sim *engine.Simulation) []blocks.Record {
+	var res []blocks.Record
+	for _, s := range sim.GetStats() {
+		if r, ok := s.(blocks.Recorder); ok {
+			res = append(res, r.Record())
+		}
+	}
+	return res
+}
+
+// replicatedRecords returns replication results as records with the mean of
+// every metric followed by the half width of its confidence interval
+func replicatedRecords(stats []replicatedSummary) []blocks.Record {
+	res := make([]blocks.Record, len(stats))
+	for i, s := range stats {
+		res[i] = blocks.Record{
+			Collector: s.Name,
+			Type:      "replicated",
+			Metrics:   blocks.Metrics{{Name: "replications", Value: float64(s.Replications)}},
+		}
+		for _, m := range s.Metrics {
+			res[i].Metrics = append(res[i].Metrics,
+				blocks.Metric{Name: m.Metric, Value: m.Mean},
+				blocks.Metric{Name: m.Metric + "_ci95", Value: m.HalfWidth})
+		}
+	}
+	return res
+}
+
+// writeRunCSV writes one row per metric, preceded by the run parameters and
+// the collector. Per request samples are only part of the JSON output.
+func writeRunCSV(w io.Writer, out runOutput) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"topology", "lambda", "mu", "generator", "processor",
+		"cores", "seed", "collector", "type", "metric", "value"})
+	p := out.Params
+	params := []string{p.Topology, ftoa(p.Lambda), ftoa(p.Mu), p.Generator,
+		p.Processor, strconv.Itoa(p.Cores), strconv.FormatInt(p.Seed, 10)}
+	for _, r := range out.Stats {
+		for _, m := range r.Metrics {
+			row := append(append([]string{}, params...), r.Collector, r.Type,
+				m.Name, ftoa(m.Value))
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeRunJSON(w io.Writer, out runOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// runWriter returns the writer of a structured output format
+func runWriter(format string) (func(io.Writer, runOutput) error, error) {
+	switch format {
+	case "csv":
+		return writeRunCSV, nil
+	case "json":
+		return writeRunJSON, nil
+	}
+	return nil, fmt.Errorf("unknown output format: %v", format)
+}
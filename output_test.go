@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// testOutput is a run with a collector whose metrics are a number, an
+// unknown value and an infinite one, and per request samples
+var testOutput = runOutput{
+	Params: runParams{Topology: "0", Lambda: 0.01, Mu: 0.02, Generator: "MM",
+		Processor: "rtc", Cores: 2, Seed: 7},
+	Stats: []blocks.Record{{
+		Collector: "Main Stats",
+		Type:      "all",
+		Metrics: blocks.Metrics{{Name: "avg", Value: 12.5},
+			{Name: "slowdown", Value: math.NaN()}, {Name: "capacity", Value: math.Inf(1)}},
+		Columns: []string{"latency"},
+		Samples: [][]float64{{1}, {2}},
+	}},
+}
+
+func TestWriteRunCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeRunCSV(&buf, testOutput); err != nil {
+		t.Fatal(err)
+	}
+	want := `topology,lambda,mu,generator,processor,cores,seed,collector,type,metric,value
+0,0.01,0.02,MM,rtc,2,7,Main Stats,all,avg,12.5
+0,0.01,0.02,MM,rtc,2,7,Main Stats,all,slowdown,NaN
+0,0.01,0.02,MM,rtc,2,7,Main Stats,all,capacity,+Inf
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestWriteRunJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeRunJSON(&buf, testOutput); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Params runParams
+		Stats  []struct {
+			Collector string
+			Type      string
+			Metrics   json.RawMessage
+			Columns   []string
+			Samples   [][]float64
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%v in\n%v", err, buf.String())
+	}
+	if got.Params != testOutput.Params {
+		t.Errorf("params %+v, want %+v", got.Params, testOutput.Params)
+	}
+	if len(got.Stats) != 1 {
+		t.Fatalf("%v records, want 1", len(got.Stats))
+	}
+	r := got.Stats[0]
+	if r.Collector != "Main Stats" || r.Type != "all" || len(r.Samples) != 2 || len(r.Columns) != 1 {
+		t.Errorf("record %+v", r)
+	}
+	// Metrics keep their order, with null for the values that are not numbers
+	var metrics bytes.Buffer
+	if err := json.Compact(&metrics, r.Metrics); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"avg":12.5,"slowdown":null,"capacity":null}`; metrics.String() != want {
+		t.Errorf("metrics %v, want %v", metrics.String(), want)
+	}
+}
+
+func TestRunWriter(t *testing.T) {
+	for _, format := range []string{"csv", "json"} {
+		if _, err := runWriter(format); err != nil {
+			t.Errorf("%v: %v", format, err)
+		}
+	}
+	if _, err := runWriter("xml"); err == nil {
+		t.Error("xml: no error")
+	}
+}
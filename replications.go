@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// replicatedMetrics returns the metrics of a summary averaged over
+// replications: the mean latency and its selected percentiles, the mean
+// queueing delay and its percentiles, and the throughput
+func replicatedMetrics(s blocks.Summary) blocks.Metrics {
+	res := blocks.Metrics{{Name: "avg", Value: s.Avg}}
+	res = append(res, s.Percentiles...)
+	res = append(res, blocks.Metric{Name: "queue_delay", Value: s.QueueDelay})
+	res = appendPrefixed(res, "queue_delay_", s.QueueDelayPercentiles)
+	return append(res, blocks.Metric{Name: "reqs_per_time_unit", Value: s.Throughput})
+}
+
+// metricCI is the mean of a metric over replications and the half width of
+// its 95% confidence interval
+type metricCI struct {
+	Metric    string  `json:"metric"`
+	Mean      float64 `json:"mean"`
+	HalfWidth float64 `json:"ci95"`
+}
+
+// replicatedSummary holds the statistics of one collector over independent
+// replications
+type replicatedSummary struct {
+	Name         string     `json:"name"`
+	Replications int        `json:"replications"`
+	Metrics      []metricCI `json:"metrics"`
+}
+
+// replicationSeeds derives the seeds of n replications from the base seed,
+// so that a set of replications is reproducible
+func replicationSeeds(seed int64, n int) []int64 {
+	r := rand.New(rand.NewSource(seed))
+	res := make([]int64, n)
+	for i := range res {
+		res[i] = r.Int63()
+	}
+	return res
+}
+
+// runReplications runs n independently seeded copies of the simulation, up
+// to parallel at a time, and returns the mean and confidence interval of the
+// metrics of every collector. Collectors are matched by name, and request
+// classes missing from some replications, which had no request of the
+// class, are reported over the replications that have them.
+func runReplications(f *simFlags, lambda float64, seed int64, n, parallel int) ([]replicatedSummary, error) {
+	seeds := replicationSeeds(seed, n)
+	runs := make([][]blocks.Summary, n)
+	errs := make([]error, n)
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range seeds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			runs[i], errs[i] = runPoint(f, lambda, seeds[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return replicate(runs), nil
+}
+
+// replicate returns the mean and confidence interval of the metrics of every
+// collector of the replications, in order of first appearance
+func replicate(runs [][]blocks.Summary) []replicatedSummary {
+	var names []string
+	byName := make(map[string][]blocks.Metrics)
+	for _, run := range runs {
+		for _, s := range run {
+			if _, ok := byName[s.Name]; !ok {
+				names = append(names, s.Name)
+			}
+			byName[s.Name] = append(byName[s.Name], replicatedMetrics(s))
+		}
+	}
+
+	res := make([]replicatedSummary, len(names))
+	for c, name := range names {
+		metrics := byName[name]
+		res[c] = replicatedSummary{Name: name, Replications: len(metrics)}
+		for j, m := range metrics[0] {
+			samples := make([]float64, len(metrics))
+			for i := range metrics {
+				samples[i] = metrics[i][j].Value
+			}
+			mean, hw := blocks.MeanCI(samples)
+			res[c].Metrics = append(res[c].Metrics, metricCI{m.Name, mean, hw})
+		}
+	}
+	return res
+}
+
+func printReplications(w io.Writer, stats []replicatedSummary) {
+	for _, s := range stats {
+		fmt.Fprintf(w, "Stats collector: %v\n", s.Name)
+		fmt.Fprintf(w, "Replications: %v\n", s.Replications)
+		fmt.Fprintf(w, "Metric\tMean\tCI95\n")
+		for _, m := range s.Metrics {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", m.Metric, m.Mean, m.HalfWidth)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// Request classes without requests in some replications are reported over
+// the replications that have them
+func TestReplicateMissingClass(t *testing.T) {
+	runs := [][]blocks.Summary{
+		{{Name: "stats", Avg: 10}, {Name: "stats/long", Avg: 100}, {Name: "stats/short", Avg: 1}},
+		{{Name: "stats", Avg: 12}, {Name: "stats/short", Avg: 3}},
+		{{Name: "stats", Avg: 14}, {Name: "stats/long", Avg: 200}, {Name: "stats/short", Avg: 2}},
+	}
+	tests := []struct {
+		name         string
+		replications int
+		avg          float64
+	}{
+		{"stats", 3, 12},
+		{"stats/long", 2, 150},
+		{"stats/short", 3, 2},
+	}
+	got := replicate(runs)
+	if len(got) != len(tests) {
+		t.Fatalf("%v collectors, want %v", len(got), len(tests))
+	}
+	for i, tt := range tests {
+		s := got[i]
+		if s.Name != tt.name || s.Replications != tt.replications {
+			t.Errorf("collector %v: %v over %v replications, want %v over %v",
+				i, s.Name, s.Replications, tt.name, tt.replications)
+		}
+		if m := s.Metrics[0]; m.Metric != "avg" || m.Mean != tt.avg || math.IsNaN(m.HalfWidth) {
+			t.Errorf("%v: %+v, want avg %v", tt.name, m, tt.avg)
+		}
+	}
+}
+
+// Replications are reproducible from the base seed, and independent
+func TestReplicationSeeds(t *testing.T) {
+	a, b := replicationSeeds(42, 10), replicationSeeds(42, 10)
+	seen := make(map[int64]bool)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Errorf("seed %v: %v then %v", i, a[i], b[i])
+		}
+		if seen[a[i]] {
+			t.Errorf("seed %v: %v repeated", i, a[i])
+		}
+		seen[a[i]] = true
+	}
+	if c := replicationSeeds(43, 1); c[0] == a[0] {
+		t.Errorf("base seeds 42 and 43 give the same first seed %v", c[0])
+	}
+}
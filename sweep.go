@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/epfl-dcsl/schedsim/analytic"
+	"github.com/epfl-dcsl/schedsim/blocks"
+)
+
+// sweepPoint is the result of a single load point of a sweep. Replicated
+// replaces Stats when every point is run several times. Analytic is the
+// latency predicted by queueing theory, if asked for.
+type sweepPoint struct {
+	Lambda     float64             `json:"lambda"`
+	Load       float64             `json:"load"`
+	Stats      []blocks.Summary    `json:"stats,omitempty"`
+	Replicated []replicatedSummary `json:"replicated,omitempty"`
+	Analytic   *analytic.Result    `json:"analytic,omitempty"`
+}
+
+// capacity returns the arrival rate that keeps the cores of the selected
+// topology busy. For topology files it follows from the mean service times
+// of the generators rather than from --mu.
+func (f *simFlags) capacity() (float64, error) {
+	cores, err := f.cores()
+	if err != nil {
+		return 0, err
+	}
+	if f.config == "" {
+		return float64(cores) * f.params.Mu, nil
+	}
+	c, err := f.loadConfig()
+	if err != nil {
+		return 0, err
+	}
+	demand, err := analytic.ConfigDemand(c)
+	if err != nil {
+		return 0, fmt.Errorf("capacity of %v: %v", f.config, err)
+	}
+	return float64(cores) / demand, nil
+}
+
+// sweepPoints returns the points of the given load levels, which are
+// arrival rates or, with load, fractions of the capacity of the topology
+func (f *simFlags) sweepPoints(levels []float64, load bool) ([]sweepPoint, error) {
+	capacity, err := f.capacity()
+	if err != nil {
+		return nil, err
+	}
+	points := make([]sweepPoint, len(levels))
+	for i, l := range levels {
+		if load {
+			points[i] = sweepPoint{Lambda: l * capacity, Load: l}
+		} else {
+			points[i] = sweepPoint{Lambda: l, Load: l / capacity}
+		}
+	}
+	return points, nil
+}
+
+// parseLevels parses either a comma separated list of values or a
+// start:stop:step range, with stop included
+func parseLevels(list, rng string) ([]float64, error) {
+	var res []float64
+	if list != "" {
+		for _, v := range strings.Split(list, ",") {
+			l, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad load level %q: %v", v, err)
+			}
+			res = append(res, l)
+		}
+	}
+	if rng != "" {
+		parts := strings.Split(rng, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("bad range %q: expected start:stop:step", rng)
+		}
+		var bounds [3]float64
+		for i, v := range parts {
+			b, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad range %q: %v", rng, err)
+			}
+			bounds[i] = b
+		}
+		if bounds[2] <= 0 {
+			return nil, fmt.Errorf("bad range %q: step should be positive", rng)
+		}
+		// Compute every level from the start to avoid accumulating errors
+		steps := int(math.Floor((bounds[1]-bounds[0])/bounds[2] + 1e-9))
+		for i := 0; i <= steps; i++ {
+			l := bounds[0] + float64(i)*bounds[2]
+			res = append(res, math.Round(l*1e9)/1e9)
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no load levels given, use --lambdas or --range")
+	}
+	return res, nil
+}
+
+// runPoint runs a single simulation for the given arrival rate and returns
+// the summaries of all its drains, each followed by the summaries of its
+// request classes. Every drain should have a summary.
+func runPoint(f *simFlags, lambda float64, seed int64) ([]blocks.Summary, error) {
+	sim, err := f.buildSim(lambda, seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sim.GetStats() {
+		if _, ok := s.(blocks.RequestDrain); !ok {
+			continue
+		}
+		if _, ok := s.(blocks.Summarizer); !ok {
+			return nil, fmt.Errorf("drain %T has no summary, use the all or book drain", s)
+		}
+	}
+	sim.Run(f.duration)
+
+	var res []blocks.Summary
+	for _, s := range sim.GetStats() {
+		if k, ok := s.(blocks.Summarizer); ok {
+			sum := k.GetSummary()
+			perClass := sum.PerClass
+			sum.PerClass = nil
+			res = append(res, sum)
+			res = append(res, perClass...)
+		}
+	}
+	return res, nil
+}
+
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// appendPrefixed appends the metrics m to res with prefixed names
+func appendPrefixed(res blocks.Metrics, prefix string, m blocks.Metrics) blocks.Metrics {
+	for _, v := range m {
+		res = append(res, blocks.Metric{Name: prefix + v.Name, Value: v.Value})
+	}
+	return res
+}
+
+// sweepMetrics returns the columns of a summary in sweeps, with the
+// selected latency, queueing delay and slowdown percentiles
+func sweepMetrics(s blocks.Summary) blocks.Metrics {
+	res := blocks.Metrics{
+		{Name: "count", Value: float64(s.Count)},
+		{Name: "stolen", Value: float64(s.Stolen)},
+		{Name: "avg", Value: s.Avg},
+		{Name: "stddev", Value: s.StdDev},
+	}
+	res = append(res, s.Percentiles...)
+	res = append(res,
+		blocks.Metric{Name: "reqs_per_time_unit", Value: s.Throughput},
+		blocks.Metric{Name: "service_time", Value: s.ServiceTime},
+		blocks.Metric{Name: "queue_delay", Value: s.QueueDelay})
+	res = appendPrefixed(res, "queue_delay_", s.QueueDelayPercentiles)
+	res = append(res, blocks.Metric{Name: "slowdown", Value: s.Slowdown})
+	return appendPrefixed(res, "slowdown_", s.SlowdownPercentiles)
+}
+
+// metricRow returns the values of m in the order of the columns, empty for
+// the missing ones and, with blankNaN, the unknown ones
+func metricRow(columns []string, m blocks.Metrics, blankNaN bool) []string {
+	values := make(map[string]float64, len(m))
+	for _, v := range m {
+		values[v.Name] = v.Value
+	}
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		if v, ok := values[c]; ok && !(blankNaN && math.IsNaN(v)) {
+			row[i] = ftoa(v)
+		}
+	}
+	return row
+}
+
+func writeSweepCSV(w io.Writer, points []sweepPoint) error {
+	if len(points) > 0 && points[0].Replicated != nil {
+		return writeReplicatedCSV(w, points)
+	}
+	cw := csv.NewWriter(w)
+	// All the collectors report the same percentiles
+	var columns []string
+	if len(points) > 0 && len(points[0].Stats) > 0 {
+		for _, m := range sweepMetrics(points[0].Stats[0]) {
+			columns = append(columns, m.Name)
+		}
+	}
+	cw.Write(append([]string{"lambda", "load", "collector"}, columns...))
+	for _, p := range points {
+		for _, s := range p.Stats {
+			row := []string{ftoa(p.Lambda), ftoa(p.Load), s.Name}
+			cw.Write(append(row, metricRow(columns, sweepMetrics(s), false)...))
+		}
+		if p.Analytic != nil {
+			s := analyticSummary(*p.Analytic)
+			// The model has no request count
+			m := sweepMetrics(s)[2:]
+			row := []string{ftoa(p.Lambda), ftoa(p.Load), s.Name}
+			cw.Write(append(row, metricRow(columns, m, true)...))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeReplicatedCSV writes the mean of every metric followed by the half
+// width of its 95% confidence interval
+func writeReplicatedCSV(w io.Writer, points []sweepPoint) error {
+	cw := csv.NewWriter(w)
+	// All the collectors report the same metrics
+	var columns []string
+	if len(points) > 0 && len(points[0].Replicated) > 0 {
+		for _, m := range points[0].Replicated[0].Metrics {
+			columns = append(columns, m.Metric)
+		}
+	}
+	header := []string{"lambda", "load", "collector", "replications"}
+	for _, c := range columns {
+		header = append(header, c, c+"_ci95")
+	}
+	cw.Write(header)
+	for _, p := range points {
+		for _, s := range p.Replicated {
+			row := []string{ftoa(p.Lambda), ftoa(p.Load), s.Name, strconv.Itoa(s.Replications)}
+			for _, m := range s.Metrics {
+				row = append(row, ftoa(m.Mean), ftoa(m.HalfWidth))
+			}
+			cw.Write(row)
+		}
+		if p.Analytic != nil {
+			s := analyticSummary(*p.Analytic)
+			row := []string{ftoa(p.Lambda), ftoa(p.Load), s.Name, ""}
+			for _, v := range metricRow(columns, replicatedMetrics(s), true) {
+				row = append(row, v, "")
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSweepJSON(w io.Writer, points []sweepPoint) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(points)
+}
+
+// sweep runs the same topology for several load levels in parallel and
+// writes one table with the statistics of every load point
+func sweep(args []string) {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	f := addSimFlags(fs)
+	var lambdas = fs.String("lambdas", "", "comma separated list of load levels")
+	var rng = fs.String("range", "", "load levels as start:stop:step, stop included")
+	var load = fs.Bool("load", false, "load levels are utilisation fractions of cores*mu instead of lambdas")
+	var format = fs.String("format", "csv", "output format: csv or json")
+	var out = fs.String("out", "", "output file, stdout if empty")
+	var parallel = fs.Int("parallel", runtime.NumCPU(), "number of simulations to run in parallel")
+	var withModel = fs.Bool("analytic", false, "add the latency predicted by queueing theory to every point")
+	fs.Parse(args)
+
+	levels, err := parseLevels(*lambdas, *rng)
+	if err != nil {
+		fatal(err)
+	}
+	var write func(io.Writer, []sweepPoint) error
+	switch *format {
+	case "csv":
+		write = writeSweepCSV
+	case "json":
+		write = writeSweepJSON
+	default:
+		fatal(fmt.Errorf("unknown output format: %v", *format))
+	}
+	if *parallel < 1 {
+		*parallel = 1
+	}
+
+	// All points share the seed (common random numbers), so differences
+	// between points come from the load and not from the random streams.
+	// Replications of a point run sequentially, points in parallel.
+	seed := f.getSeed()
+	points, err := f.sweepPoints(levels, *load)
+	if err != nil {
+		fatal(err)
+	}
+	if *withModel {
+		// Check the model before running the simulations
+		quantiles, err := f.analyticQuantiles()
+		if err != nil {
+			fatal(err)
+		}
+		for i := range points {
+			r, err := f.analyticPoint(points[i].Lambda, quantiles)
+			if err != nil {
+				fatal(err)
+			}
+			points[i].Analytic = &r
+		}
+	}
+	errs := make([]error, len(levels))
+	sem := make(chan struct{}, *parallel)
+	var wg sync.WaitGroup
+	for i := range points {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if f.replications > 1 {
+				points[i].Replicated, errs[i] = runReplications(f, points[i].Lambda, seed, f.replications, 1)
+			} else {
+				points[i].Stats, errs[i] = runPoint(f, points[i].Lambda, seed)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := write(w, points); err != nil {
+		fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	tests := []struct {
+		list, rng string
+		want      []float64
+		err       string // expected error substring, empty if valid
+	}{
+		{"0.01, 0.02,0.05", "", []float64{0.01, 0.02, 0.05}, ""},
+		{"", "0.1:0.5:0.1", []float64{0.1, 0.2, 0.3, 0.4, 0.5}, ""},
+		{"", "0.1:0.45:0.1", []float64{0.1, 0.2, 0.3, 0.4}, ""},
+		{"", "1:1:1", []float64{1}, ""},
+		{"0.05", "0.1:0.2:0.1", []float64{0.05, 0.1, 0.2}, ""},
+		{"", "", nil, "no load levels given"},
+		{"0.1,x", "", nil, `bad load level "x"`},
+		{"", "0.1:0.5", nil, "expected start:stop:step"},
+		{"", "0.1:0.5:x", nil, "bad range"},
+		{"", "0.1:0.5:0", nil, "step should be positive"},
+		{"", "0.5:0.1:0.1", nil, "no load levels given"},
+	}
+	for _, tt := range tests {
+		got, err := parseLevels(tt.list, tt.rng)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q, %q: got error %v, want %q", tt.list, tt.rng, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%q, %q: got %v, %v, want %v", tt.list, tt.rng, got, err, tt.want)
+		}
+	}
+}
@@ -1,52 +1,54 @@
 package topologies
 
 import (
-	"fmt"
-
 	"github.com/epfl-dcsl/schedsim/blocks"
 	"github.com/epfl-dcsl/schedsim/engine"
 )
 
-func BoundedQueue(lambda, mu, duration float64, bufferSize int) {
-
-	engine.InitSim()
+// BoundedQueue describes a two stage topology where the first processor
+// drops requests when the buffer to the second one is full. Every core has
+// its own two stages.
+func BoundedQueue(sim *engine.Simulation, params Params) error {
 
 	//Init the statistics
-	stats := &blocks.AllKeeper{}
-	stats.SetName("Main Stats")
-	engine.InitStats(stats)
+	stats, err := params.newStats(sim, "Main Stats")
+	if err != nil {
+		return err
+	}
 
-	droppedStats := &blocks.AllKeeper{}
-	droppedStats.SetName("Dropped Stats")
-	engine.InitStats(droppedStats)
+	droppedStats, err := params.newStats(sim, "Dropped Stats")
+	if err != nil {
+		return err
+	}
 
 	// Add generator
 	var g blocks.Generator
-	g = blocks.NewMDRandGenerator(lambda, 1/mu)
+	g = blocks.NewMDRandGenerator(params.Lambda, 1/params.Mu)
 
-	g.SetCreator(&blocks.ColoredReqCreator{})
+	g.SetCreator(blocks.NewColoredReqCreator(sim))
 
-	// Create queues
-	q1 := blocks.NewQueue()
-	q2 := blocks.NewQueue()
+	// Every core has its own two stages, fed at random
+	for i := 0; i < params.Cores; i++ {
+		// Create queues
+		q1 := blocks.NewQueue()
+		q2 := blocks.NewQueue()
 
-	// Create processors
-	p1 := blocks.NewBoundedProcessor(bufferSize)
-	p2 := &blocks.BoundedProcessor2{}
+		// Create processors
+		p1 := blocks.NewBoundedProcessor(params.BufferSize)
+		p2 := &blocks.BoundedProcessor2{}
 
-	g.AddOutQueue(q1)
-	p1.AddInQueue(q1)
-	p1.AddOutQueue(q2)
-	p1.SetReqDrain(droppedStats)
-	engine.RegisterActor(p1)
+		g.AddOutQueue(q1)
+		p1.AddInQueue(q1)
+		p1.AddOutQueue(q2)
+		p1.SetReqDrain(droppedStats)
+		sim.RegisterActor(p1)
 
-	p2.AddInQueue(q2)
-	p2.SetReqDrain(stats)
-	engine.RegisterActor(p2)
+		p2.AddInQueue(q2)
+		p2.SetReqDrain(stats)
+		sim.RegisterActor(p2)
+	}
 
 	// Register the generator
-	engine.RegisterActor(g)
-
-	fmt.Printf("Cores:%v\tservice_rate:%v\tinterarrival_rate:%v\n", cores, mu, lambda)
-	engine.Run(duration)
+	sim.RegisterActor(g)
+	return nil
 }
@@ -1,5 +1,211 @@
 package topologies
 
-const (
-	cores = 1
+import (
+	"fmt"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
 )
+
+// Params holds the parameters of the predefined topologies.
+// Generator and Processor select registered components by name and take
+// precedence over GenType and ProcType.
+type Params struct {
+	Topo         int     // single queue (0), multi queue (1), bounded queue (2), dispatcher (3)
+	Cores        int     // number of cores, each with its own processor
+	Lambda       float64 // arrival rate
+	Mu           float64 // service rate per core
+	GenType      int
+	ProcType     int
+	BufferSize   int // only used by the bounded queue
+	Generator    string
+	GenParams    blocks.Params
+	Dispatch     string  // dispatch policy of the generator, its own if empty
+	DispatchCost float64 // time of every decision of the dispatcher core
+	Processor    string
+	ProcParams   blocks.Params
+	Drain        string // registered drain collecting the statistics, all if empty
+}
+
+// genTypes maps the GenType selector to registered generators
+var genTypes = []struct {
+	name   string
+	params func(lambda, mu float64) blocks.Params
+}{
+	{"MMRand", func(lambda, mu float64) blocks.Params {
+		return blocks.Params{"lambda": lambda, "mu": mu}
+	}},
+	{"MDRand", func(lambda, mu float64) blocks.Params {
+		return blocks.Params{"lambda": lambda, "serviceTime": 1 / mu}
+	}},
+	{"MBRand", func(lambda, mu float64) blocks.Params {
+		return blocks.Params{"lambda": lambda, "peak1": 1.0, "peak2": 10 * (1/mu - 0.9), "ratio": 0.9}
+	}},
+	{"MBRand", func(lambda, mu float64) blocks.Params {
+		return blocks.Params{"lambda": lambda, "peak1": 1.0, "peak2": 1000 * (1/mu - 0.999), "ratio": 0.999}
+	}},
+}
+
+// procTypes maps the ProcType selector to registered processors
+var procTypes = []string{"rtc", "ps", "steal"}
+
+// GeneratorName returns the name of the registered generator selected by p
+func (p Params) GeneratorName() (string, error) {
+	if p.Generator != "" {
+		return p.Generator, nil
+	}
+	if p.GenType < 0 || p.GenType >= len(genTypes) {
+		return "", fmt.Errorf("unknown generator type: %v", p.GenType)
+	}
+	return genTypes[p.GenType].name, nil
+}
+
+// GeneratorParams returns the name of the registered generator selected by
+// p and its parameters, with the arrival and service rates filled in unless
+// explicitly given
+func (p Params) GeneratorParams() (string, blocks.Params, error) {
+	if p.Generator == "" {
+		if p.GenType < 0 || p.GenType >= len(genTypes) {
+			return "", nil, fmt.Errorf("unknown generator type: %v", p.GenType)
+		}
+		gt := genTypes[p.GenType]
+		return gt.name, gt.params(p.Lambda, p.Mu), nil
+	}
+
+	params := blocks.Params{}
+	info, _ := blocks.LookupGenerator(p.Generator)
+	if info.HasParam("lambda") {
+		params["lambda"] = p.Lambda
+	}
+	if info.HasParam("mu") {
+		params["mu"] = p.Mu
+	}
+	for k, v := range p.GenParams {
+		params[k] = v
+	}
+	return p.Generator, params, nil
+}
+
+func (p Params) newGenerator() (blocks.Generator, error) {
+	name, params, err := p.GeneratorParams()
+	if err != nil {
+		return nil, err
+	}
+	g, err := blocks.NewGenerator(name, params)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetDispatch(g, p.Dispatch); err != nil {
+		return nil, fmt.Errorf("generator %v: %v", name, err)
+	}
+	return g, nil
+}
+
+// SetDispatch sets the dispatch policy of g, given as accepted by
+// blocks.ParseDispatcher. An empty policy keeps the one of the generator.
+func SetDispatch(g blocks.Generator, dispatch string) error {
+	if dispatch == "" {
+		return nil
+	}
+	ds, ok := g.(blocks.DispatchSetter)
+	if !ok {
+		return fmt.Errorf("no dispatch policy")
+	}
+	d, err := blocks.ParseDispatcher(dispatch)
+	if err != nil {
+		return err
+	}
+	ds.SetDispatcher(d)
+	return nil
+}
+
+// ProcessorName returns the name of the registered processor selected by p
+func (p Params) ProcessorName() (string, error) {
+	if p.Processor != "" {
+		return p.Processor, nil
+	}
+	if p.ProcType < 0 || p.ProcType >= len(procTypes) {
+		return "", fmt.Errorf("unknown processor type: %v", p.ProcType)
+	}
+	return procTypes[p.ProcType], nil
+}
+
+func (p Params) newProcessor(extra blocks.Params) (blocks.Processor, error) {
+	name, err := p.ProcessorName()
+	if err != nil {
+		return nil, err
+	}
+	params := blocks.Params{}
+	for k, v := range extra {
+		params[k] = v
+	}
+	for k, v := range p.ProcParams {
+		params[k] = v
+	}
+	return blocks.NewProcessor(name, params)
+}
+
+// watchWorkers tells g, if it dispatches from the requests outstanding at
+// every worker, the processors serving every queue, and checks that its
+// dispatch policy knows them all
+func watchWorkers(g blocks.Generator, servers map[engine.QueueInterface][]blocks.Processor) error {
+	w, ok := g.(blocks.WorkerWatcher)
+	if !ok {
+		return nil
+	}
+	for q, procs := range servers {
+		var reporters []blocks.ServiceReporter
+		for _, p := range procs {
+			if r, ok := p.(blocks.ServiceReporter); ok {
+				reporters = append(reporters, r)
+			}
+		}
+		w.WatchWorkers(q, reporters...)
+	}
+	return w.ValidateWorkers()
+}
+
+// busyReporters returns the processors that can tell if they are busy
+func busyReporters(procs ...blocks.Processor) []blocks.BusyReporter {
+	var res []blocks.BusyReporter
+	for _, p := range procs {
+		if b, ok := p.(blocks.BusyReporter); ok {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// newStats creates a drain of the selected type, named name, that collects
+// statistics for sim
+func (p Params) newStats(sim *engine.Simulation, name string) (blocks.StatsDrain, error) {
+	drain := p.Drain
+	if drain == "" {
+		drain = "all"
+	}
+	stats, err := blocks.NewDrain(drain, nil)
+	if err != nil {
+		return nil, err
+	}
+	stats.SetName(name)
+	sim.InitStats(stats)
+	return stats, nil
+}
+
+// Build adds the topology selected by p.Topo to sim
+func Build(sim *engine.Simulation, p Params) error {
+	if p.Cores < 1 {
+		return fmt.Errorf("bad core count: %v", p.Cores)
+	}
+	switch p.Topo {
+	case 0:
+		return SingleQueue(sim, p)
+	case 1:
+		return MultiQueue(sim, p)
+	case 2:
+		return BoundedQueue(sim, p)
+	case 3:
+		return DispatcherQueue(sim, p)
+	}
+	return fmt.Errorf("unknown topology: %v", p.Topo)
+}
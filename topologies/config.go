@@ -0,0 +1,299 @@
+package topologies
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// DrainConfig describes a request drain collecting statistics
+type DrainConfig struct {
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	Params blocks.Params `json:"params"`
+}
+
+// GeneratorConfig describes a generator and the queues it feeds
+type GeneratorConfig struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`
+	Params   blocks.Params `json:"params"`
+	Creator  string        `json:"creator"`  // simple if empty
+	Dispatch string        `json:"dispatch"` // the one of the generator if empty
+	Out      []string      `json:"out"`
+}
+
+// ProcessorConfig describes a processor, its queues and its drain.
+// If Count is larger than one, Count identical processors are created, and
+// Count processors per core with PerCore. The out queues of a dispatcher are
+// its worker queues: the processors whose first in queue is one of them
+// notify the dispatcher of their completions.
+type ProcessorConfig struct {
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	Params  blocks.Params `json:"params"`
+	Count   int           `json:"count"`
+	PerCore bool          `json:"perCore"`
+	In      []string      `json:"in"`
+	Out     []string      `json:"out"`
+	Drain   string        `json:"drain"`
+}
+
+// Config is a declarative description of a topology. Components refer to
+// queues and drains by name. Cores is the number of cores of the
+// processors created per core, one if not given.
+type Config struct {
+	Cores      int               `json:"cores"`
+	Queues     []string          `json:"queues"`
+	Drains     []DrainConfig     `json:"drains"`
+	Generators []GeneratorConfig `json:"generators"`
+	Processors []ProcessorConfig `json:"processors"`
+}
+
+// LoadConfig reads a JSON topology description from path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return c, nil
+}
+
+// SetLambda overrides the lambda parameter of every generator that has one
+func (c *Config) SetLambda(lambda float64) {
+	for i := range c.Generators {
+		if info, ok := blocks.LookupGenerator(c.Generators[i].Type); !ok || !info.HasParam("lambda") {
+			continue
+		}
+		if c.Generators[i].Params == nil {
+			c.Generators[i].Params = blocks.Params{}
+		}
+		c.Generators[i].Params["lambda"] = lambda
+	}
+}
+
+// SetCores overrides the number of cores
+func (c *Config) SetCores(cores int) {
+	c.Cores = cores
+}
+
+// processorCount returns the number of processors created by pc
+func (c *Config) processorCount(pc ProcessorConfig) int {
+	count := pc.Count
+	if count == 0 {
+		count = 1
+	}
+	if pc.PerCore && c.Cores > 0 {
+		count *= c.Cores
+	}
+	return count
+}
+
+// isDispatcher returns true if pc describes dispatchers rather than the
+// processors serving requests
+func isDispatcher(pc ProcessorConfig) bool {
+	p, err := blocks.NewProcessor(pc.Type, pc.Params)
+	if err != nil {
+		return false
+	}
+	_, ok := p.(blocks.DispatchReporter)
+	return ok
+}
+
+// Components returns the generator and processor types used by the
+// configuration, without duplicates, and the number of processors serving
+// requests, dispatchers left out
+func (c *Config) Components() (generators, processors []string, count int) {
+	seen := map[string]bool{}
+	for _, gc := range c.Generators {
+		if !seen["g"+gc.Type] {
+			seen["g"+gc.Type] = true
+			generators = append(generators, gc.Type)
+		}
+	}
+	for _, pc := range c.Processors {
+		if !seen["p"+pc.Type] {
+			seen["p"+pc.Type] = true
+			processors = append(processors, pc.Type)
+		}
+		if !isDispatcher(pc) {
+			count += c.processorCount(pc)
+		}
+	}
+	return
+}
+
+// Build adds the topology described by the configuration to sim
+func (c *Config) Build(sim *engine.Simulation) error {
+	if c.Cores < 0 {
+		return fmt.Errorf("bad core count: %v", c.Cores)
+	}
+	queues := make(map[string]engine.QueueInterface)
+	for _, name := range c.Queues {
+		if _, ok := queues[name]; ok {
+			return fmt.Errorf("duplicate queue: %v", name)
+		}
+		queues[name] = blocks.NewQueue()
+	}
+	getQueue := func(component, name string) (engine.QueueInterface, error) {
+		q, ok := queues[name]
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown queue: %v", component, name)
+		}
+		return q, nil
+	}
+
+	//Init the statistics
+	drains := make(map[string]blocks.RequestDrain)
+	for _, dc := range c.Drains {
+		if _, ok := drains[dc.Name]; ok {
+			return fmt.Errorf("duplicate drain: %v", dc.Name)
+		}
+		drainType := dc.Type
+		if drainType == "" {
+			drainType = "all"
+		}
+		d, err := blocks.NewDrain(drainType, dc.Params)
+		if err != nil {
+			return fmt.Errorf("%v: %v", dc.Name, err)
+		}
+		d.SetName(dc.Name)
+		sim.InitStats(d)
+		drains[dc.Name] = d
+	}
+
+	// Create and register the processors. Dispatchers are connected to
+	// their output queues, and stealers to their victim queues, once the
+	// processors serving them exist.
+	var dispatchers []*blocks.CentralDispatcher
+	var dispatcherOut [][]engine.QueueInterface
+	workers := make(map[engine.QueueInterface][]blocks.CompletionNotifier)
+	var stealers []blocks.Stealer
+	var victims [][]engine.QueueInterface
+	owners := make(map[engine.QueueInterface][]blocks.Processor)
+	servers := make(map[engine.QueueInterface][]blocks.Processor)
+	for _, pc := range c.Processors {
+		d, ok := drains[pc.Drain]
+		if !ok {
+			return fmt.Errorf("%v: unknown drain: %v", pc.Name, pc.Drain)
+		}
+		if len(pc.In) == 0 {
+			return fmt.Errorf("%v: no input queues", pc.Name)
+		}
+		count := c.processorCount(pc)
+		for i := 0; i < count; i++ {
+			p, err := blocks.NewProcessor(pc.Type, pc.Params)
+			if err != nil {
+				return fmt.Errorf("%v: %v", pc.Name, err)
+			}
+			stealer, isStealer := p.(blocks.Stealer)
+			var stolen []engine.QueueInterface
+			for j, name := range pc.In {
+				q, err := getQueue(pc.Name, name)
+				if err != nil {
+					return err
+				}
+				if j > 0 && isStealer {
+					stolen = append(stolen, q)
+					continue
+				}
+				p.AddInQueue(q)
+				servers[q] = append(servers[q], p)
+				if j == 0 {
+					owners[q] = append(owners[q], p)
+				}
+				if n, ok := p.(blocks.CompletionNotifier); ok && j == 0 {
+					workers[q] = append(workers[q], n)
+				}
+			}
+			if isStealer {
+				stealers = append(stealers, stealer)
+				victims = append(victims, stolen)
+			}
+			dispatcher, isDispatcher := p.(*blocks.CentralDispatcher)
+			var out []engine.QueueInterface
+			for _, name := range pc.Out {
+				q, err := getQueue(pc.Name, name)
+				if err != nil {
+					return err
+				}
+				if isDispatcher {
+					out = append(out, q)
+				} else {
+					p.AddOutQueue(q)
+				}
+			}
+			if isDispatcher {
+				if len(out) == 0 {
+					return fmt.Errorf("%v: no output queues", pc.Name)
+				}
+				dispatchers = append(dispatchers, dispatcher)
+				dispatcherOut = append(dispatcherOut, out)
+			}
+			p.SetReqDrain(d)
+			sim.RegisterActor(p)
+		}
+	}
+
+	// Stealers take the requests waiting behind the busy owners of their
+	// victim queues, the processors whose first in queue they are
+	for i, s := range stealers {
+		for _, q := range victims[i] {
+			s.AddVictimQueue(q, busyReporters(owners[q]...)...)
+		}
+	}
+
+	// The processors whose own queue is fed by a dispatcher notify it
+	for i, d := range dispatchers {
+		for _, q := range dispatcherOut[i] {
+			d.AddWorker(q, workers[q]...)
+		}
+		if err := d.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Create and register the generators
+	for _, gc := range c.Generators {
+		if len(gc.Out) == 0 {
+			return fmt.Errorf("%v: no output queues", gc.Name)
+		}
+		g, err := blocks.NewGenerator(gc.Type, gc.Params)
+		if err != nil {
+			return fmt.Errorf("%v: %v", gc.Name, err)
+		}
+		if err := SetDispatch(g, gc.Dispatch); err != nil {
+			return fmt.Errorf("%v: %v", gc.Name, err)
+		}
+		creator := gc.Creator
+		if creator == "" {
+			creator = "simple"
+		}
+		rc, err := blocks.NewCreator(sim, creator, nil)
+		if err != nil {
+			return fmt.Errorf("%v: %v", gc.Name, err)
+		}
+		g.SetCreator(rc)
+		out := make(map[engine.QueueInterface][]blocks.Processor)
+		for _, name := range gc.Out {
+			q, err := getQueue(gc.Name, name)
+			if err != nil {
+				return err
+			}
+			g.AddOutQueue(q)
+			out[q] = servers[q]
+		}
+		if err := watchWorkers(g, out); err != nil {
+			return fmt.Errorf("%v: %v", gc.Name, err)
+		}
+		sim.RegisterActor(g)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package topologies_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+	"github.com/epfl-dcsl/schedsim/topologies"
+)
+
+// loadConfig writes the configuration given as JSON to a file and loads it
+func loadConfig(t *testing.T, config string) *topologies.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := topologies.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("loading %v: %v", config, err)
+	}
+	return c
+}
+
+// run builds c and runs it for duration, and returns the summary of every
+// drain
+func run(t *testing.T, c *topologies.Config, duration float64) []blocks.Summary {
+	t.Helper()
+	sim := engine.InitSim(1)
+	if err := c.Build(sim); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	sim.Run(duration)
+	var res []blocks.Summary
+	for _, s := range sim.GetStats() {
+		res = append(res, s.(blocks.Summarizer).GetSummary())
+	}
+	return res
+}
+
+// twoQueues returns a configuration with a generator feeding two queues
+// served by one processor each, or one if served is false
+func twoQueues(t *testing.T, gen string, served bool) *topologies.Config {
+	t.Helper()
+	procs := `{"name": "core0", "type": "rtc", "in": ["q0"], "drain": "stats"}`
+	if served {
+		procs += `, {"name": "core1", "type": "rtc", "in": ["q1"], "drain": "stats"}`
+	}
+	return loadConfig(t, `{
+		"queues": ["q0", "q1"],
+		"drains": [{"name": "stats"}],
+		"generators": [`+gen+`],
+		"processors": [`+procs+`]
+	}`)
+}
+
+// The dispatch policy of a generator comes from its dispatch field, or its
+// own params, and is checked against the processors serving its queues
+func TestConfigGeneratorDispatch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		gen    string
+		served bool
+		err    string // expected error substring, empty if valid
+	}{
+		{`{"type": "MM", "params": {"lambda": 0.01, "mu": 0.1}, "dispatch": "jsq", "out": ["q0", "q1"]}`, true, ""},
+		{`{"type": "MM", "params": {"lambda": 0.01, "mu": 0.1}, "dispatch": "pod(d=2)", "out": ["q0", "q1"]}`, true, ""},
+		{`{"type": "MM", "params": {"lambda": 0.01, "mu": 0.1}, "dispatch": "jiq", "out": ["q0", "q1"]}`, true, ""},
+		{`{"type": "dist", "params": {"lambda": 0.01, "service": "exponential(lambda=0.1)", "dispatch": "jbsq(bound=2)"}, "out": ["q0", "q1"]}`, true, ""},
+		{`{"name": "gen", "type": "MM", "params": {"lambda": 0.01, "mu": 0.1}, "dispatch": "jiq", "out": ["q0", "q1"]}`, false,
+			"gen: the dispatch policy needs to know the processors serving output queue 1"},
+		{`{"name": "gen", "type": "MM", "params": {"lambda": 0.01, "mu": 0.1}, "dispatch": "pod(d=0)", "out": ["q0", "q1"]}`, true,
+			"gen: pod: d must be at least 1"},
+		{`{"name": "gen", "type": "MM", "params": {"lambda": 0.01, "mu": 0.1}, "dispatch": "shortest", "out": ["q0", "q1"]}`, true,
+			"gen: unknown dispatcher: shortest"},
+		{`{"name": "gen", "type": "dist", "params": {"lambda": 0.01, "service": "exponential(lambda=0.1", "dispatch": "jsq"}, "out": ["q0", "q1"]}`, true,
+			"missing )"},
+	}
+	for _, tt := range tests {
+		c := twoQueues(t, tt.gen, tt.served)
+		if tt.err != "" {
+			err := c.Build(engine.InitSim(1))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: got error %v, want %q", tt.gen, err, tt.err)
+			}
+			continue
+		}
+		summaries := run(t, c, 100000)
+		if got := summaries[0].Count; got < 900 || got > 1100 {
+			t.Errorf("%v: %v requests served, want about 1000", tt.gen, got)
+		}
+	}
+}
+
+// The lambda of the command line replaces the one of every generator that
+// has one
+func TestConfigSetLambda(t *testing.T) {
+	t.Parallel()
+	c := twoQueues(t, `{"type": "MM", "params": {"mu": 0.1}, "out": ["q0", "q1"]}`, true)
+	c.SetLambda(0.02)
+	if got := run(t, c, 100000)[0].Count; got < 1800 || got > 2200 {
+		t.Errorf("%v requests served, want about 2000", got)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	t.Parallel()
+	if _, err := topologies.LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file: no error")
+	}
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte(`{"queues": "q"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := topologies.LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "queues") {
+		t.Errorf("bad queues: got error %v, want the path and the field", err)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	t.Parallel()
+	paths, err := filepath.Glob("../configs/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no configuration files: %v", err)
+	}
+	for _, path := range paths {
+		c, err := topologies.LoadConfig(path)
+		if err != nil {
+			t.Errorf("%v: %v", path, err)
+			continue
+		}
+		for _, s := range run(t, c, 10000) {
+			if s.Count == 0 {
+				t.Errorf("%v: %v served no request", path, s.Name)
+			}
+		}
+	}
+}
+
+// The processor count leaves the dispatchers out, and grows with the cores
+// for the processors created per core
+func TestConfigComponents(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path       string
+		cores      int
+		generators []string
+		processors []string
+		count      int
+	}{
+		{"../configs/dispatcher.json", 4, []string{"MM"}, []string{"dispatcher", "rtc"}, 4},
+		{"../configs/single_queue.json", 3, []string{"MMRand"}, []string{"rtc"}, 3},
+		{"../configs/multi_queue.json", 0, []string{"MBRand"}, []string{"rtc", "ps"}, 4},
+	}
+	for _, tt := range tests {
+		c, err := topologies.LoadConfig(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetCores(tt.cores)
+		generators, processors, count := c.Components()
+		if !slices.Equal(generators, tt.generators) || !slices.Equal(processors, tt.processors) || count != tt.count {
+			t.Errorf("%v: got %v, %v, %v, want %v, %v, %v", tt.path,
+				generators, processors, count, tt.generators, tt.processors, tt.count)
+		}
+	}
+}
+
+// dispatcherConfig returns a configuration with a central dispatcher
+// feeding two worker queues, with the given processors after the dispatcher
+func dispatcherConfig(t *testing.T, dispatcher, processors string) *topologies.Config {
+	t.Helper()
+	return loadConfig(t, `{
+		"queues": ["central", "w0", "w1"],
+		"drains": [{"name": "stats"}],
+		"generators": [{"name": "gen", "type": "MM", "params": {"lambda": 0.01, "mu": 0.05}, "out": ["central"]}],
+		"processors": [`+dispatcher+`, `+processors+`]
+	}`)
+}
+
+func TestConfigDispatcher(t *testing.T) {
+	t.Parallel()
+	const workers = `{"name": "core0", "type": "rtc", "in": ["w0"], "drain": "stats"},
+		{"name": "core1", "type": "rtc", "in": ["w1"], "drain": "stats"}`
+	tests := []struct {
+		dispatcher string
+		processors string
+		err        string // expected error substring, empty if valid
+	}{
+		{`{"name": "dispatcher", "type": "dispatcher", "in": ["central"], "out": ["w0", "w1"], "drain": "stats"}`,
+			workers, ""},
+		{`{"name": "dispatcher", "type": "dispatcher", "params": {"cost": 1, "dispatch": "jsq"}, "in": ["central"], "out": ["w0", "w1"], "drain": "stats"}`,
+			workers, ""},
+		{`{"name": "dispatcher", "type": "dispatcher", "params": {"cost": 1, "dispatch": "jiq"}, "in": ["central"], "out": ["w0", "w1"], "drain": "stats"}`,
+			`{"name": "core", "type": "ps", "count": 2, "in": ["w0"], "drain": "stats"},
+			{"name": "core1", "type": "rtc", "in": ["w1"], "drain": "stats"}`, ""},
+		{`{"name": "dispatcher", "type": "dispatcher", "in": ["central"], "out": ["w0", "w1"], "drain": "stats"}`,
+			`{"name": "core0", "type": "rtc", "in": ["w0"], "drain": "stats"}`,
+			"no processor serves worker queue 1"},
+		{`{"name": "dispatcher", "type": "dispatcher", "in": ["central"], "drain": "stats"}`,
+			workers, "dispatcher: no output queues"},
+		{`{"name": "dispatcher", "type": "dispatcher", "params": {"cost": -1}, "in": ["central"], "out": ["w0", "w1"], "drain": "stats"}`,
+			workers, "should not be negative"},
+		{`{"name": "dispatcher", "type": "dispatcher", "in": ["central", "w0"], "out": ["w0", "w1"], "drain": "stats"}`,
+			workers, "expected a single central queue"},
+	}
+	for _, tt := range tests {
+		c := dispatcherConfig(t, tt.dispatcher, tt.processors)
+		if tt.err != "" {
+			err := c.Build(engine.InitSim(1))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: got error %v, want %q", tt.dispatcher, err, tt.err)
+			}
+			continue
+		}
+		if got := run(t, c, 100000)[0].Count; got < 900 || got > 1100 {
+			t.Errorf("%v: %v requests served, want about 1000", tt.dispatcher, got)
+		}
+	}
+}
+
+func TestConfigBuildErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{`{"cores": -1}`, "bad core count: -1"},
+		{`{"queues": ["q", "q"]}`, "duplicate queue: q"},
+		{`{"drains": [{"name": "stats"}, {"name": "stats"}]}`, "duplicate drain: stats"},
+		{`{"drains": [{"name": "stats", "type": "histogram"}]}`, "stats: unknown drain: histogram"},
+		{`{"queues": ["q"], "processors": [{"name": "core", "type": "rtc", "in": ["q"], "drain": "stats"}]}`,
+			"core: unknown drain: stats"},
+		{`{"drains": [{"name": "stats"}], "processors": [{"name": "core", "type": "rtc", "drain": "stats"}]}`,
+			"core: no input queues"},
+		{`{"drains": [{"name": "stats"}], "processors": [{"name": "core", "type": "rtc", "in": ["q"], "drain": "stats"}]}`,
+			"core: unknown queue: q"},
+		{`{"drains": [{"name": "stats"}], "queues": ["q"], "processors": [{"name": "core", "type": "fifo", "in": ["q"], "drain": "stats"}]}`,
+			"core: unknown processor: fifo"},
+		{`{"queues": ["q"], "generators": [{"name": "gen", "type": "MM", "params": {"lambda": 0.01, "mu": 0.1}}]}`,
+			"gen: no output queues"},
+		{`{"queues": ["q"], "generators": [{"name": "gen", "type": "MM", "params": {"lambda": 0.01, "sigma": 1}, "out": ["q"]}]}`,
+			"gen: generator MM: unknown parameter sigma"},
+		{`{"queues": ["q"], "generators": [{"name": "gen", "type": "MM", "params": {"lambda": 0.01, "mu": 0.1}, "out": ["r"]}]}`,
+			"gen: unknown queue: r"},
+	}
+	for _, tt := range tests {
+		err := loadConfig(t, tt.config).Build(engine.InitSim(1))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want %q", tt.config, err, tt.err)
+		}
+	}
+}
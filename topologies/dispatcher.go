@@ -0,0 +1,75 @@
+package topologies
+
+import (
+	"fmt"
+
+	"github.com/epfl-dcsl/schedsim/blocks"
+	"github.com/epfl-dcsl/schedsim/engine"
+)
+
+// DispatcherQueue describes a topology where the generator feeds a central
+// queue, from which a dispatcher core forwards the requests to the queues of
+// the worker cores, one per core. The dispatch policy is the one of the
+// dispatcher rather than the generator, and every decision takes
+// DispatchCost, 0 for an ideal dispatcher.
+func DispatcherQueue(sim *engine.Simulation, params Params) error {
+
+	//Init the statistics
+	stats, err := params.newStats(sim, "Main Stats")
+	if err != nil {
+		return err
+	}
+
+	// Create the dispatcher
+	policy := params.Dispatch
+	if policy == "" {
+		policy = "jbsq(bound=1)"
+	}
+	d, err := blocks.ParseDispatcher(policy)
+	if err != nil {
+		return fmt.Errorf("dispatcher: %v", err)
+	}
+	dispatcher := blocks.NewCentralDispatcher(params.DispatchCost, d)
+
+	// Add generator, feeding the central queue
+	params.Dispatch = ""
+	g, err := params.newGenerator()
+	if err != nil {
+		return err
+	}
+	g.SetCreator(blocks.NewSimpleReqCreator(sim))
+	central := blocks.NewQueue()
+	g.AddOutQueue(central)
+	dispatcher.AddInQueue(central)
+
+	// Create the workers, each with its own queue
+	for i := 0; i < params.Cores; i++ {
+		p, err := params.newProcessor(nil)
+		if err != nil {
+			return err
+		}
+		n, ok := p.(blocks.CompletionNotifier)
+		if !ok {
+			return fmt.Errorf("processor does not notify its completions")
+		}
+		q := blocks.NewQueue()
+		p.AddInQueue(q)
+		dispatcher.AddWorker(q, n)
+		p.SetReqDrain(stats)
+		sim.RegisterActor(p)
+	}
+
+	if err := dispatcher.Validate(); err != nil {
+		return err
+	}
+
+	err = watchWorkers(g, map[engine.QueueInterface][]blocks.Processor{central: {dispatcher}})
+	if err != nil {
+		return err
+	}
+
+	// Register the dispatcher and the generator
+	sim.RegisterActor(dispatcher)
+	sim.RegisterActor(g)
+	return nil
+}
@@ -1,71 +1,80 @@
 package topologies
 
 import (
-	"fmt"
-
 	"github.com/epfl-dcsl/schedsim/blocks"
 	"github.com/epfl-dcsl/schedsim/engine"
 )
 
 // MultiQueue describes a single-generator-multi-processor topology where every
-// processor has its own incoming queue
-func MultiQueue(lambda, mu, duration float64, genType, procType int) {
-
-	engine.InitSim()
+// processor has its own incoming queue. Stealing processors can also steal
+// from the queues of the other processors, starting with the next one.
+func MultiQueue(sim *engine.Simulation, params Params) error {
 
 	//Init the statistics
-	//stats := blocks.NewBookKeeper()
-	stats := &blocks.AllKeeper{}
-	stats.SetName("Main Stats")
-	engine.InitStats(stats)
+	stats, err := params.newStats(sim, "Main Stats")
+	if err != nil {
+		return err
+	}
 
 	// Add generator
-	var g blocks.Generator
-	if genType == 0 {
-		g = blocks.NewMMRandGenerator(lambda, mu)
-	} else if genType == 1 {
-		g = blocks.NewMDRandGenerator(lambda, 1/mu)
-	} else if genType == 2 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 10*(1/mu-0.9), 0.9)
-	} else if genType == 3 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 1000*(1/mu-0.999), 0.999)
+	g, err := params.newGenerator()
+	if err != nil {
+		return err
 	}
 
-	g.SetCreator(&blocks.SimpleReqCreator{})
-
 	// Create queues
-	fastQueues := make([]engine.QueueInterface, cores)
+	fastQueues := make([]engine.QueueInterface, params.Cores)
 	for i := range fastQueues {
 		fastQueues[i] = blocks.NewQueue()
 	}
 
 	// Create processors
-	processors := make([]blocks.Processor, cores)
+	processors := make([]blocks.Processor, params.Cores)
 
 	// first the slow cores
-	for i := 0; i < cores; i++ {
-		if procType == 0 {
-			processors[i] = &blocks.RTCProcessor{}
-		} else if procType == 1 {
-			processors[i] = blocks.NewPSProcessor()
+	for i := 0; i < params.Cores; i++ {
+		processors[i], err = params.newProcessor(nil)
+		if err != nil {
+			return err
 		}
 	}
 
 	// Connect the fast queues
+	stealing := false
 	for i, q := range fastQueues {
 		g.AddOutQueue(q)
 		processors[i].AddInQueue(q)
+		if s, ok := processors[i].(blocks.Stealer); ok {
+			stealing = true
+			for j := 1; j < len(fastQueues); j++ {
+				victim := (i + j) % len(fastQueues)
+				s.AddVictimQueue(fastQueues[victim], busyReporters(processors[victim])...)
+			}
+		}
+	}
+
+	servers := make(map[engine.QueueInterface][]blocks.Processor)
+	for i, q := range fastQueues {
+		servers[q] = processors[i : i+1]
+	}
+	if err := watchWorkers(g, servers); err != nil {
+		return err
+	}
+
+	// Stealable requests account for steals
+	if stealing {
+		g.SetCreator(blocks.NewStealableReqCreator(sim))
+	} else {
+		g.SetCreator(blocks.NewSimpleReqCreator(sim))
 	}
 
 	// Add the stats and register processors
 	for _, p := range processors {
 		p.SetReqDrain(stats)
-		engine.RegisterActor(p)
+		sim.RegisterActor(p)
 	}
 
 	// Register the generator
-	engine.RegisterActor(g)
-
-	fmt.Printf("Cores:%v\tservice_rate:%v\tinterarrival_rate:%v\n", cores, mu, lambda)
-	engine.Run(duration)
+	sim.RegisterActor(g)
+	return nil
 }
@@ -1,62 +1,66 @@
 package topologies
 
 import (
-	"fmt"
-
 	"github.com/epfl-dcsl/schedsim/blocks"
 	"github.com/epfl-dcsl/schedsim/engine"
 )
 
 // SingleQueue implement a single-generator-multiprocessor topology with a single
 // queue. Each processor just dequeues from this queue
-func SingleQueue(lambda, mu, duration float64, genType, procType int) {
-
-	engine.InitSim()
+func SingleQueue(sim *engine.Simulation, params Params) error {
 
 	//Init the statistics
-	stats := &blocks.AllKeeper{}
-	stats.SetName("Main Stats")
-	engine.InitStats(stats)
+	stats, err := params.newStats(sim, "Main Stats")
+	if err != nil {
+		return err
+	}
 
 	// Add generator
-	var g blocks.Generator
-	if genType == 0 {
-		g = blocks.NewMMRandGenerator(lambda, mu)
-	} else if genType == 1 {
-		g = blocks.NewMDRandGenerator(lambda, 1/mu)
-	} else if genType == 2 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 10*(1/mu-0.9), 0.9)
-	} else if genType == 3 {
-		g = blocks.NewMBRandGenerator(lambda, 1, 1000*(1/mu-0.999), 0.999)
+	g, err := params.newGenerator()
+	if err != nil {
+		return err
 	}
 
-	g.SetCreator(&blocks.SimpleReqCreator{})
+	g.SetCreator(blocks.NewSimpleReqCreator(sim))
 
 	// Create queues
 	q := blocks.NewQueue()
 
 	// Create processors
-
-	if procType == 0 {
-		for i := 0; i < cores; i++ {
-			p := &blocks.RTCProcessor{}
-			p.AddInQueue(q)
-			p.SetReqDrain(stats)
-			engine.RegisterActor(p)
+	// A processor sharing processor shares all the cores
+	procName, err := params.ProcessorName()
+	if err != nil {
+		return err
+	}
+	var processors []blocks.Processor
+	if procName == "ps" {
+		p, err := params.newProcessor(blocks.Params{"workers": params.Cores})
+		if err != nil {
+			return err
 		}
-	} else if procType == 1 {
-		p := blocks.NewPSProcessor()
-		p.SetWorkerCount(cores)
 		p.AddInQueue(q)
 		p.SetReqDrain(stats)
-		engine.RegisterActor(p)
+		sim.RegisterActor(p)
+		processors = append(processors, p)
+	} else {
+		for i := 0; i < params.Cores; i++ {
+			p, err := params.newProcessor(nil)
+			if err != nil {
+				return err
+			}
+			p.AddInQueue(q)
+			p.SetReqDrain(stats)
+			sim.RegisterActor(p)
+			processors = append(processors, p)
+		}
 	}
 
 	g.AddOutQueue(q)
+	if err := watchWorkers(g, map[engine.QueueInterface][]blocks.Processor{q: processors}); err != nil {
+		return err
+	}
 
 	// Register the generator
-	engine.RegisterActor(g)
-
-	fmt.Printf("Cores:%v\tservice_rate:%v\tinterarrival_rate:%v\n", cores, mu, lambda)
-	engine.Run(duration)
+	sim.RegisterActor(g)
+	return nil
 }